@@ -29,11 +29,67 @@ func main() {
 	quarter := strings.ToLower(os.Getenv("QUARTER"))
 	campus := strings.ToLower(os.Getenv("CAMPUS"))
 	crntoadd := os.Getenv("CRNSTOADD")
+	crnstodrop := os.Getenv("CRNSTODROP")
+	swapCRNs := os.Getenv("SWAP_CRNS")
 	retryamount := os.Getenv("RETRY_AMOUNT")
 	retryduration := os.Getenv("RETRY_DURATION")
 	webhookURL := os.Getenv("DISCORD_WEBHOOK")
+	sessionDir := os.Getenv("SESSION_DIR")
+	institutionPath := os.Getenv("INSTITUTION")
+	pageSize := os.Getenv("PAGE_SIZE")
+	pageConcurrency := os.Getenv("PAGE_CONCURRENCY")
+	searchConcurrency := os.Getenv("SEARCH_CONCURRENCY")
+	outputFormat := os.Getenv("OUTPUT_FORMAT")
+	output := os.Getenv("OUTPUT")
+	snapshotDB := os.Getenv("SNAPSHOT_DB")
+	historyDays := os.Getenv("HISTORY_DAYS")
+	watchCRNs := os.Getenv("WATCH_CRNS")
+	pollInterval := os.Getenv("POLL_INTERVAL")
+	pollJitter := os.Getenv("POLL_JITTER")
+	allowWaitlist := os.Getenv("ALLOW_WAITLIST")
+	dryRun := os.Getenv("DRY_RUN")
+	allowConflicts := os.Getenv("ALLOW_CONFLICTS")
+	registrationLead := os.Getenv("REGISTRATION_LEAD")
+	registrationPoll := os.Getenv("REGISTRATION_POLL")
+	keepAlive := os.Getenv("KEEP_ALIVE")
+	courseNumber := os.Getenv("COURSE_NUMBER")
+	keyword := os.Getenv("KEYWORD")
+	title := os.Getenv("TITLE")
+	instructor := os.Getenv("INSTRUCTOR")
+	openOnly := os.Getenv("OPEN_ONLY")
+	sectionCampus := os.Getenv("SECTION_CAMPUS")
+	instructionalMethod := os.Getenv("INSTRUCTIONAL_METHOD")
+	attributes := os.Getenv("ATTRIBUTES")
+	days := os.Getenv("DAYS")
+	startTime := os.Getenv("START_TIME")
+	endTime := os.Getenv("END_TIME")
+	creditHours := os.Getenv("CREDIT_HOURS")
+	courses := os.Getenv("COURSES")
+	scheduleCount := os.Getenv("SCHEDULES")
+	preferStart := os.Getenv("PREFER_START")
+	preferEnd := os.Getenv("PREFER_END")
+	daysOff := os.Getenv("DAYS_OFF")
+	preferInstructors := os.Getenv("PREFER_INSTRUCTORS")
+	preferMethod := os.Getenv("PREFER_METHOD")
+
+	// Keep progress messages out of exports piped through stdout.
+	stdout := os.Stdout
+	if output == tasks.StdoutOutput {
+		os.Stdout = os.Stderr
+	}
+
+	institution := tasks.FHDA
+	if institutionPath != "" {
+		institution, err = tasks.LoadInstitution(institutionPath)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Printf("Loaded institution: %s\n", institution.Name)
+	}
 
 	t := &tasks.Task{}
+	t.Institution = institution
 
 	jar := tls_client.NewCookieJar()
 	client_options := []tls_client.HttpClientOption{
@@ -41,10 +97,19 @@ func main() {
 		tls_client.WithCookieJar(jar),
 	}
 	t.Client, _ = tls_client.NewHttpClient(tls_client.NewLogger(), client_options...)
+	if mode == "SNIPE" {
+		t.SearchClient, _ = tls_client.NewHttpClient(tls_client.NewLogger(),
+			tls_client.WithClientProfile(profiles.Chrome_117),
+			tls_client.WithCookieJar(tls_client.NewCookieJar()),
+		)
+	}
 	t.UserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/117.0.0.0 Safari/537.36"
 	t.Username = username
 	t.Password = password
-	t.Subject = subject
+	t.Subjects = splitList(subject)
+	if len(t.Subjects) > 0 {
+		t.Subject = t.Subjects[0]
+	}
 
 	retryAmount, err := strconv.Atoi(retryamount)
 	if err != nil {
@@ -57,24 +122,211 @@ func main() {
 
 	t.RetryAmount = retryAmount
 	t.RetryDuration = time.Duration(retryDuration * int(time.Second))
-	t.CoursesToAdd = strings.Split(crntoadd, ",")
+	t.CourseChoices = tasks.ParseCourseChoices(crntoadd)
+	t.CoursesToAdd = nil
+	for _, choice := range t.CourseChoices {
+		t.CoursesToAdd = append(t.CoursesToAdd, choice...)
+	}
+	t.CoursesToDrop = splitList(crnstodrop)
+	t.CoursesToSwap, err = tasks.ParseSwaps(swapCRNs)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if pageSize != "" {
+		t.PageSize, err = strconv.Atoi(pageSize)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+	if pageConcurrency != "" {
+		t.PageConcurrency, err = strconv.Atoi(pageConcurrency)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+	if searchConcurrency != "" {
+		t.SearchConcurrency, err = strconv.Atoi(searchConcurrency)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
 	t.WebhookURL = webhookURL
 
-	if mode == "SEARCH" || mode == "SIGNUP" {
-		yearint, err := strconv.Atoi(year)
+	t.OutputFormat, err = tasks.ParseExportFormat(outputFormat)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	t.Output = output
+	t.Stdout = stdout
+
+	t.Criteria = tasks.SearchCriteria{
+		Subject:             t.Subject,
+		CourseNumber:        courseNumber,
+		Keyword:             keyword,
+		Title:               title,
+		Instructor:          instructor,
+		OpenOnly:            strings.EqualFold(openOnly, "true"),
+		Campus:              sectionCampus,
+		InstructionalMethod: instructionalMethod,
+		Days:                strings.ToUpper(days),
+	}
+	if attributes != "" {
+		t.Criteria.Attributes = strings.Split(attributes, ",")
+	}
+	if startTime != "" {
+		t.Criteria.StartTime, err = tasks.ParseTimeOfDay(startTime)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+	if endTime != "" {
+		t.Criteria.EndTime, err = tasks.ParseTimeOfDay(endTime)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+	if creditHours != "" {
+		t.Criteria.CreditHoursMin, t.Criteria.CreditHoursMax, err = tasks.ParseCreditHours(creditHours)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	t.DesiredCourses, err = tasks.ParseDesiredCourses(courses)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if scheduleCount != "" {
+		t.ScheduleCount, err = strconv.Atoi(scheduleCount)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+	t.Preferences = tasks.SchedulePreferences{
+		DaysOff:             strings.ToUpper(daysOff),
+		Instructors:         splitList(preferInstructors),
+		InstructionalMethod: preferMethod,
+	}
+	if preferStart != "" {
+		t.Preferences.StartTime, err = tasks.ParseTimeOfDay(preferStart)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+	if preferEnd != "" {
+		t.Preferences.EndTime, err = tasks.ParseTimeOfDay(preferEnd)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	if sessionDir == "" {
+		sessionDir = ".sessions"
+	}
+	if sessionDir != "off" {
+		t.SessionStore = tasks.NewSessionStore(sessionDir)
+	}
+
+	if snapshotDB != "" {
+		t.Snapshots, err = tasks.OpenSnapshotStore(snapshotDB)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		defer t.Snapshots.Close()
+	}
+	t.WatchCRNs = splitList(watchCRNs)
+	if pollInterval != "" {
+		seconds, err := strconv.Atoi(pollInterval)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		t.PollInterval = time.Duration(seconds) * time.Second
+	}
+	if pollJitter != "" {
+		seconds, err := strconv.Atoi(pollJitter)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		t.PollJitter = time.Duration(seconds) * time.Second
+	}
+	t.AllowWaitlist = strings.EqualFold(allowWaitlist, "true")
+	t.DryRun = strings.EqualFold(dryRun, "true")
+	t.AllowConflicts = strings.EqualFold(allowConflicts, "true")
+	if registrationLead != "" {
+		milliseconds, err := strconv.Atoi(registrationLead)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		t.RegistrationLead = time.Duration(milliseconds) * time.Millisecond
+	}
+	if registrationPoll != "" {
+		milliseconds, err := strconv.Atoi(registrationPoll)
 		if err != nil {
 			fmt.Println(err)
 			return
 		}
-		termId, err := tasks.BuildTermId(yearint, campus, quarter)
-		t.TermId = termId
+		t.RegistrationPoll = time.Duration(milliseconds) * time.Millisecond
+	}
+	if keepAlive != "" {
+		seconds, err := strconv.Atoi(keepAlive)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		t.KeepAlive = time.Duration(seconds) * time.Second
+	}
+	if historyDays != "" {
+		days, err := strconv.Atoi(historyDays)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		t.HistorySince = time.Duration(days) * 24 * time.Hour
+	}
 
-		termDesc, err := t.SearchTerm()
+	if mode == "SEARCH" || mode == "SIGNUP" || mode == "HISTORY" || mode == "WATCH" || mode == "SNIPE" || mode == "DROP" || mode == "SWAP" || mode == "GENERATE" || mode == "SCHEDULE" {
+		yearint, err := strconv.Atoi(year)
 		if err != nil {
-			fmt.Printf("Warning: Term not found\n")
-		} else {
-			fmt.Printf("Found term: %s\n", termDesc)
+			fmt.Println(err)
+			return
+		}
+		for _, quarter := range splitList(quarter) {
+			termId, err := institution.BuildTermId(yearint, campus, quarter)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			t.TermIds = append(t.TermIds, termId)
+			if mode == "HISTORY" {
+				continue
+			}
+
+			termDesc, err := t.FindTerm(termId)
+			if err != nil {
+				fmt.Printf("Warning: Term %s not found\n", termId)
+			} else {
+				fmt.Printf("Found term: %s\n", termDesc)
+			}
 		}
+		if len(t.TermIds) == 0 {
+			fmt.Println(tasks.InvalidQuarter)
+			return
+		}
+		t.TermId = t.TermIds[0]
 	}
 
 	switch mode {
@@ -92,6 +344,55 @@ func main() {
 				fmt.Println(err)
 			}
 		}
+	case "DROP":
+		{
+			signup := tasks.NewSignupTask(t)
+			if err := signup.Drop(); err != nil {
+				fmt.Println(err)
+			}
+		}
+	case "SWAP":
+		{
+			signup := tasks.NewSignupTask(t)
+			if err := signup.Swap(); err != nil {
+				fmt.Println(err)
+			}
+		}
+	case "WATCH":
+		{
+			watch := tasks.NewWatchTask(t)
+			if err := watch.Run(); err != nil {
+				fmt.Println(err)
+			}
+		}
+	case "SNIPE":
+		{
+			snipe := tasks.NewSnipeTask(t)
+			if err := snipe.Run(); err != nil {
+				fmt.Println(err)
+			}
+		}
+	case "SCHEDULE":
+		{
+			schedule := tasks.NewScheduleTask(t)
+			if err := schedule.Run(); err != nil {
+				fmt.Println(err)
+			}
+		}
+	case "GENERATE":
+		{
+			generate := tasks.NewGenerateTask(t)
+			if err := generate.Run(); err != nil {
+				fmt.Println(err)
+			}
+		}
+	case "HISTORY":
+		{
+			history := tasks.NewHistoryTask(t)
+			if err := history.Run(); err != nil {
+				fmt.Println(err)
+			}
+		}
 	case "TRANSCRIPT":
 		{
 			transcript := tasks.NewTranscriptTask(t)
@@ -103,7 +404,20 @@ func main() {
 		fmt.Println("Invalid mode selected")
 	}
 
+	if output == tasks.StdoutOutput {
+		return
+	}
 	for {
 		time.Sleep(time.Second)
 	}
 }
+
+func splitList(value string) []string {
+	var values []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	return values
+}
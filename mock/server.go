@@ -0,0 +1,1126 @@
+// Package mock emulates the Banner 9 registration, DegreeWorks, Shibboleth
+// and WSO2 endpoints Veil talks to so that tasks can be exercised end to end
+// without network access.
+package mock
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	RegistrationPath = "/StudentRegistrationSsb"
+	DegreeWorksPath  = "/responsiveDashboard"
+	LoginPath        = "/idp/profile/SAML2/Redirect/SSO"
+	CommonAuthPath   = "/commonauth"
+	SAMLSSOPath      = "/samlsso"
+	SSOManagerPath   = "/ssomanager"
+	WebhookPath      = "/webhook"
+
+	RegistrationACSPath = RegistrationPath + "/saml/SSO/alias/registrationssb-prod-sp"
+	DegreeWorksACSPath  = DegreeWorksPath + "/saml/SSO"
+	SSOManagerACSPath   = SSOManagerPath + "/saml/SSO"
+)
+
+const (
+	RoleIdP          = "idp"
+	RoleBroker       = "broker"
+	RoleSSOManager   = "ssomanager"
+	RoleRegistration = "registration"
+	RoleDegreeWorks  = "degreeworks"
+)
+
+type Term struct {
+	Code        string
+	Description string
+}
+
+type Section struct {
+	Term                string
+	CRN                 string
+	Subject             string
+	CourseNumber        string
+	Sequence            string
+	Title               string
+	Campus              string
+	CampusCode          string
+	ScheduleType        string
+	CreditHours         float64
+	Capacity            int
+	Enrolled            int
+	WaitCapacity        int
+	WaitCount           int
+	Instructor          string
+	InstructorEmail     string
+	Days                string
+	Begin               string
+	End                 string
+	StartDate           string
+	EndDate             string
+	Building            string
+	Room                string
+	InstructionalMethod string
+	Attributes          []string
+	LinkIdentifier      string
+}
+
+type Student struct {
+	ID                string
+	Name              string
+	School            string
+	SchoolDescription string
+	Degree            string
+	DegreeDescription string
+}
+
+type AuditClass struct {
+	Term       string
+	Discipline string
+	Number     string
+	Title      string
+	Grade      string
+	Credits    string
+}
+
+// Server is a scriptable stand-in for an institution. Fields may be changed
+// between requests; handlers read them under the server lock.
+type Server struct {
+	*httptest.Server
+
+	Username            string
+	Password            string
+	Terms               []Term
+	Sections            []Section
+	Student             Student
+	Audit               []AuditClass
+	RegistrationOpensAt time.Time
+	EligibilityFailures []string
+	AddErrors           map[string]string
+	CRNErrors           map[string]string
+	BeforeRequest       func(r *http.Request)
+	// MaxPageSize caps pageMaxSize of class searches like Banner does, 0
+	// for no cap.
+	MaxPageSize int
+	// AddFailures answers that many addRegistrationItem calls for a CRN with
+	// a server error before adding it.
+	AddFailures map[string]int
+	// ClockSkew sets the server clock ahead of the local one, both in Date
+	// headers and when checking RegistrationOpensAt.
+	ClockSkew time.Duration
+
+	mu         sync.Mutex
+	sessions   map[string]string
+	assertions map[string]bool
+	searchTerm map[string]string
+	searchKey  map[string]string
+	registered map[string]bool
+	waitlisted map[string]int
+	pending    map[string]bool
+	dropped    map[string]bool
+	webhooks   []string
+	logins     int
+
+	// historyTerm is the term the registration history page was reset to.
+	historyTerm string
+}
+
+func NewServer() *Server {
+	s := &Server{
+		Username:   "20000000",
+		Password:   "password",
+		AddErrors:  map[string]string{},
+		CRNErrors:  map[string]string{},
+		sessions:   map[string]string{},
+		assertions: map[string]bool{},
+		searchTerm: map[string]string{},
+		searchKey:  map[string]string{},
+		registered: map[string]bool{},
+		waitlisted: map[string]int{},
+		pending:    map[string]bool{},
+		dropped:    map[string]bool{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+func (s *Server) RegistrationURL() string {
+	return s.URL + RegistrationPath
+}
+
+func (s *Server) DegreeWorksURL() string {
+	return s.URL + DegreeWorksPath
+}
+
+func (s *Server) LoginURL() string {
+	return s.URL + LoginPath + "?execution=e1s%d"
+}
+
+func (s *Server) WebhookURL() string {
+	return s.URL + WebhookPath
+}
+
+// Logins returns the number of successful credential submissions.
+func (s *Server) Logins() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.logins
+}
+
+// Register marks crn as already registered, as if by an earlier session.
+func (s *Server) Register(crn string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.registered[crn] = true
+}
+
+func (s *Server) Registered(crn string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.registered[crn]
+}
+
+// Pending reports whether crn was added to the cart and neither submitted
+// nor removed since.
+func (s *Server) Pending(crn string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pending[crn]
+}
+
+// WaitlistPosition returns the waitlist position of crn, or 0 when the
+// student is not waitlisted.
+func (s *Server) WaitlistPosition(crn string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.waitlisted[crn]
+}
+
+func (s *Server) Webhooks() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.webhooks...)
+}
+
+// ExpireSessions invalidates every session of the given roles, or all
+// sessions when no role is given.
+func (s *Server) ExpireSessions(roles ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for token, role := range s.sessions {
+		if len(roles) == 0 || contains(roles, role) {
+			delete(s.sessions, token)
+		}
+	}
+}
+
+func (s *Server) now() time.Time {
+	return time.Now().Add(s.ClockSkew)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.BeforeRequest != nil {
+		s.BeforeRequest(r)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w.Header().Set("Date", s.now().UTC().Format(http.TimeFormat))
+	path := r.URL.Path
+	switch {
+	case path == WebhookPath:
+		s.handleWebhook(w, r)
+	case path == LoginPath:
+		s.handleLogin(w, r)
+	case path == CommonAuthPath:
+		s.handleCommonAuth(w, r)
+	case path == SAMLSSOPath:
+		s.handleSAMLSSO(w, r)
+	case path == SSOManagerPath+"/saml/login":
+		s.redirectToLogin(w, r, RoleSSOManager)
+	case path == SSOManagerACSPath:
+		s.handleACS(w, r, RoleSSOManager)
+	case path == RegistrationACSPath:
+		s.handleACS(w, r, RoleRegistration)
+	case path == DegreeWorksACSPath:
+		s.handleACS(w, r, RoleDegreeWorks)
+	case strings.HasPrefix(path, RegistrationPath+"/ssb/"):
+		s.handleRegistration(w, r, strings.TrimPrefix(path, RegistrationPath+"/ssb"))
+	case strings.HasPrefix(path, DegreeWorksPath):
+		s.handleDegreeWorks(w, r, strings.TrimPrefix(path, DegreeWorksPath))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	s.webhooks = append(s.webhooks, string(body))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) redirectToLogin(w http.ResponseWriter, r *http.Request, role string) {
+	http.SetCookie(w, &http.Cookie{Name: "idp_flow", Value: role, Path: "/"})
+	http.Redirect(w, r, LoginPath+"?execution=e1s1", http.StatusFound)
+}
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		writeLoginPage(w, "")
+		return
+	}
+	r.ParseForm()
+
+	if r.PostForm.Get("j_username") != s.Username {
+		writeLoginPage(w, "The username you entered cannot be identified.")
+		return
+	}
+	if r.PostForm.Get("j_password") != s.Password {
+		writeLoginPage(w, "The password you entered was incorrect.")
+		return
+	}
+
+	s.logins++
+	s.newSession(w, RoleIdP)
+	relayState := RoleSSOManager
+	if cookie, err := r.Cookie("idp_flow"); err == nil {
+		relayState = cookie.Value
+	}
+	writeAutoPost(w, CommonAuthPath, map[string]string{
+		"SAMLResponse": s.newAssertion(),
+		"RelayState":   relayState,
+	})
+}
+
+func (s *Server) handleCommonAuth(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	if !s.consumeAssertion(r.PostForm.Get("SAMLResponse")) {
+		http.Error(w, "invalid assertion", http.StatusBadRequest)
+		return
+	}
+	s.newSession(w, RoleBroker)
+	writeAutoPost(w, acsPath(r.PostForm.Get("RelayState")), map[string]string{
+		"SAMLResponse": s.newAssertion(),
+		"RelayState":   r.PostForm.Get("RelayState"),
+	})
+}
+
+func (s *Server) handleSAMLSSO(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	role := r.Form.Get("SAMLRequest")
+	if !s.hasSession(r, RoleBroker) {
+		s.redirectToLogin(w, r, role)
+		return
+	}
+	writeAutoPost(w, acsPath(role), map[string]string{
+		"SAMLResponse": s.newAssertion(),
+		"RelayState":   role,
+	})
+}
+
+func (s *Server) handleACS(w http.ResponseWriter, r *http.Request, role string) {
+	r.ParseForm()
+	if !s.consumeAssertion(r.PostForm.Get("SAMLResponse")) {
+		http.Error(w, "invalid assertion", http.StatusBadRequest)
+		return
+	}
+	s.newSession(w, role)
+	io.WriteString(w, "<html><body>Signed in</body></html>")
+}
+
+func (s *Server) handleRegistration(w http.ResponseWriter, r *http.Request, path string) {
+	anonymous := s.anonymousSession(w, r)
+
+	switch path {
+	case "/classSearch/getTerms":
+		var terms []map[string]string
+		for _, term := range s.Terms {
+			terms = append(terms, map[string]string{"code": term.Code, "description": term.Description})
+		}
+		writeJSON(w, terms)
+		return
+	case "/searchResults/searchResults":
+		s.handleSearchResults(w, r, searchContext(r, anonymous))
+		return
+	case "/searchResults/getFacultyMeetingTimes":
+		section, ok := s.section(r.URL.Query().Get("term"), r.URL.Query().Get("courseReferenceNumber"))
+		if !ok {
+			writeJSON(w, map[string]any{"fmt": []any{}})
+			return
+		}
+		faculty := s.sectionJSON(section)["faculty"].([]map[string]any)
+		writeJSON(w, map[string]any{"fmt": []map[string]any{meetingJSON(section, faculty)}})
+		return
+	case "/searchResults/fetchLinkedSections":
+		section, ok := s.section(r.URL.Query().Get("term"), r.URL.Query().Get("courseReferenceNumber"))
+		linkedData := [][]map[string]any{}
+		if ok {
+			for _, companion := range s.linkedSections(section) {
+				linkedData = append(linkedData, []map[string]any{s.sectionJSON(companion)})
+			}
+		}
+		writeJSON(w, map[string]any{"linkedData": linkedData})
+		return
+	case "/classSearch/resetDataForm":
+		context := searchContext(r, anonymous)
+		delete(s.searchKey, context)
+		writeJSON(w, true)
+		return
+	case "/term/search":
+		if r.URL.Query().Get("mode") == "search" {
+			r.ParseForm()
+			s.searchTerm[searchContext(r, anonymous)] = r.PostForm.Get("term")
+			writeJSON(w, map[string]string{"fwdURL": "/StudentRegistrationSsb/ssb/classSearch/classSearch"})
+			return
+		}
+	}
+
+	if !s.hasSession(r, RoleRegistration) {
+		if path == "/registration/registerPostSignIn" {
+			writeAutoPost(w, SAMLSSOPath, map[string]string{"SAMLRequest": RoleRegistration})
+			return
+		}
+		http.Redirect(w, r, SSOManagerPath+"/saml/login", http.StatusFound)
+		return
+	}
+
+	switch path {
+	case "/registration/registerPostSignIn":
+		io.WriteString(w, "<html><body>Registration</body></html>")
+	case "/term/saveTerm":
+		writeJSON(w, map[string]string{"fwdUrl": "/StudentRegistrationSsb/ssb/classRegistration/classRegistration?term=" + r.URL.Query().Get("term")})
+	case "/term/search":
+		s.handleRegistrationStatus(w)
+	case "/classRegistration/classRegistration":
+		w.WriteHeader(http.StatusOK)
+	case "/classRegistration/addRegistrationItem":
+		s.handleAddRegistrationItem(w, r)
+	case "/classRegistration/getRegistrationCart":
+		s.handleRegistrationCart(w, r)
+	case "/classRegistration/submitRegistration/batch":
+		s.handleSubmitRegistration(w, r)
+	case "/classRegistration/getRegistrationEvents":
+		s.handleRegistrationEvents(w)
+	case "/registrationHistory/reset":
+		s.handleRegistrationHistory(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// searchContext keys class search state the way Banner does: per
+// JSESSIONID and uniqueSessionId.
+func searchContext(r *http.Request, anonymous string) string {
+	r.ParseForm()
+	return anonymous + "/" + r.Form.Get("uniqueSessionId")
+}
+
+func (s *Server) handleSearchResults(w http.ResponseWriter, r *http.Request, context string) {
+	query := r.URL.Query()
+	term := query.Get("txt_term")
+	if s.searchTerm[context] != term {
+		writeJSON(w, map[string]any{"success": false, "totalCount": 0, "data": nil})
+		return
+	}
+
+	// Like Banner, a search keeps returning the results of the first query
+	// until the form is reset with resetDataForm.
+	criteria := url.Values{}
+	for key, values := range query {
+		if key != "pageOffset" && key != "pageMaxSize" && key != "uniqueSessionId" {
+			criteria[key] = values
+		}
+	}
+	if key, ok := s.searchKey[context]; ok {
+		stale, _ := url.ParseQuery(key)
+		stale.Set("pageOffset", query.Get("pageOffset"))
+		stale.Set("pageMaxSize", query.Get("pageMaxSize"))
+		query = stale
+	} else {
+		s.searchKey[context] = criteria.Encode()
+	}
+
+	var matched []Section
+	for _, section := range s.Sections {
+		if section.Term != term {
+			continue
+		}
+		if subject := query.Get("txt_subject"); subject != "" && section.Subject != subject {
+			continue
+		}
+		if number := query.Get("txt_courseNumber"); number != "" && section.CourseNumber != number {
+			continue
+		}
+		if keyword := query.Get("txt_keywordlike"); keyword != "" && !strings.Contains(strings.ToLower(section.Title), strings.ToLower(keyword)) {
+			continue
+		}
+		if query.Get("chk_open_only") == "true" && section.Enrolled >= section.Capacity {
+			continue
+		}
+		if campus := query.Get("txt_campus"); campus != "" && section.CampusCode != campus {
+			continue
+		}
+		if method := query.Get("txt_instructionalMethod"); method != "" && section.InstructionalMethod != method {
+			continue
+		}
+		if attribute := query.Get("txt_attribute"); attribute != "" && !hasAttribute(section, attribute) {
+			continue
+		}
+		if low, err := strconv.ParseFloat(query.Get("txt_credithourlow"), 64); err == nil && section.CreditHours < low {
+			continue
+		}
+		if high, err := strconv.ParseFloat(query.Get("txt_credithourhigh"), 64); err == nil && section.CreditHours > high {
+			continue
+		}
+		matched = append(matched, section)
+	}
+
+	offset, _ := strconv.Atoi(query.Get("pageOffset"))
+	size, err := strconv.Atoi(query.Get("pageMaxSize"))
+	if err != nil || size <= 0 {
+		size = 10
+	}
+	if s.MaxPageSize > 0 && size > s.MaxPageSize {
+		size = s.MaxPageSize
+	}
+	var page []map[string]any
+	for i := offset; i < len(matched) && i < offset+size; i++ {
+		page = append(page, s.sectionJSON(matched[i]))
+	}
+
+	writeJSON(w, map[string]any{
+		"success":              true,
+		"totalCount":           len(matched),
+		"data":                 page,
+		"pageOffset":           offset,
+		"pageMaxSize":          size,
+		"sectionsFetchedCount": len(matched),
+		"pathMode":             "search",
+	})
+}
+
+func (s *Server) handleRegistrationStatus(w http.ResponseWriter) {
+	failures := append([]string(nil), s.EligibilityFailures...)
+	if s.now().Before(s.RegistrationOpensAt) {
+		failures = append(failures, fmt.Sprintf(
+			"You can register from %s to %s.",
+			s.RegistrationOpensAt.Format("01/02/2006 03:04 PM"),
+			s.RegistrationOpensAt.AddDate(0, 3, 0).Format("01/02/2006 03:04 PM"),
+		))
+	}
+	writeJSON(w, map[string]any{
+		"studentEligValid":    len(failures) == 0,
+		"studentEligFailures": failures,
+		"fwdURL":              "/StudentRegistrationSsb/ssb/classRegistration/classRegistration",
+	})
+}
+
+func (s *Server) handleAddRegistrationItem(w http.ResponseWriter, r *http.Request) {
+	crn := r.URL.Query().Get("courseReferenceNumber")
+	if s.AddFailures[crn] > 0 {
+		s.AddFailures[crn]--
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if message, ok := s.AddErrors[crn]; ok {
+		writeJSON(w, map[string]any{"success": false, "message": message})
+		return
+	}
+	section, ok := s.section(r.URL.Query().Get("term"), crn)
+	if !ok {
+		writeJSON(w, map[string]any{"success": false, "message": "Invalid CRN or Term"})
+		return
+	}
+	s.pending[crn] = true
+	writeJSON(w, map[string]any{
+		"success": true,
+		"model":   registrationModel(section, "Pending"),
+	})
+}
+
+// handleRegistrationCart lists the rows of the sections the student is
+// registered in, each offering the web drop action.
+func (s *Server) handleRegistrationCart(w http.ResponseWriter, r *http.Request) {
+	rows := []map[string]any{}
+	for _, section := range s.Sections {
+		if section.Term != r.URL.Query().Get("term") || !s.registered[section.CRN] {
+			continue
+		}
+		row := registrationModel(section, "Registered")
+		row["recordStatus"] = "O"
+		row["registrationActions"] = append(row["registrationActions"].([]map[string]any),
+			map[string]any{"class": "net.hedtech.banner.student.registration.RegistrationAction", "courseRegistrationStatus": "DW", "description": "Drop Web", "remove": true},
+		)
+		rows = append(rows, row)
+	}
+	writeJSON(w, rows)
+}
+
+// handleRegistrationHistory resets the registration history page to a term
+// and lists every registration of the student in it, including drops.
+func (s *Server) handleRegistrationHistory(w http.ResponseWriter, r *http.Request) {
+	s.historyTerm = r.URL.Query().Get("term")
+	registrations := []map[string]any{}
+	for _, section := range s.Sections {
+		if section.Term != s.historyTerm {
+			continue
+		}
+		var row map[string]any
+		switch {
+		case s.registered[section.CRN]:
+			row = registrationModel(section, "Registered")
+		case s.waitlisted[section.CRN] > 0:
+			row = registrationModel(section, "Wait Listed")
+			row["courseRegistrationStatus"] = "WL"
+			row["waitPosition"] = s.waitlisted[section.CRN]
+		case s.dropped[section.CRN]:
+			row = registrationModel(section, "Deleted")
+			row["courseRegistrationStatus"] = "DW"
+		default:
+			continue
+		}
+		row["creditHour"] = section.CreditHours
+		registrations = append(registrations, row)
+	}
+	writeJSON(w, map[string]any{"success": true, "data": map[string]any{"registrations": registrations}})
+}
+
+// handleRegistrationEvents lists every class meeting of the term's
+// registered sections, as Banner's schedule calendar does.
+func (s *Server) handleRegistrationEvents(w http.ResponseWriter) {
+	events := []map[string]any{}
+	for _, section := range s.Sections {
+		if section.Term != s.historyTerm || !s.registered[section.CRN] || section.Begin == "" {
+			continue
+		}
+		start, err1 := time.Parse("01/02/2006", section.StartDate)
+		end, err2 := time.Parse("01/02/2006", section.EndDate)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		for date := start; !date.After(end); date = date.AddDate(0, 0, 1) {
+			if !strings.Contains(section.Days, string("UMTWRFS"[date.Weekday()])) {
+				continue
+			}
+			day := date.Format("2006-01-02")
+			events = append(events, map[string]any{
+				"id":           len(events) + 1,
+				"crn":          section.CRN,
+				"term":         section.Term,
+				"subject":      section.Subject,
+				"courseNumber": section.CourseNumber,
+				"title":        section.Title,
+				"start":        day + "T" + section.Begin[:2] + ":" + section.Begin[2:] + ":00",
+				"end":          day + "T" + section.End[:2] + ":" + section.End[2:] + ":00",
+				"allDay":       false,
+				"editable":     false,
+			})
+		}
+	}
+	writeJSON(w, events)
+}
+
+// handleWaitlist puts the student on the waitlist of a full section, as
+// Banner does for a row submitted with the "Wait Listed" action.
+func (s *Server) handleWaitlist(result map[string]any, section Section) {
+	if section.WaitCount >= section.WaitCapacity {
+		result["statusDescription"] = "Errors Preventing Registration"
+		result["crnErrors"] = []map[string]string{crnError("Waitlist Full")}
+		return
+	}
+	position := section.WaitCount + 1
+	for i := range s.Sections {
+		if s.Sections[i].Term == section.Term && s.Sections[i].CRN == section.CRN {
+			s.Sections[i].WaitCount = position
+		}
+	}
+	s.waitlisted[section.CRN] = position
+	result["statusDescription"] = "Wait Listed"
+	result["courseRegistrationStatus"] = "WL"
+	result["waitPosition"] = position
+	result["crnErrors"] = []any{}
+}
+
+func isWaitlistAction(model map[string]any) bool {
+	action, ok := model["selectedAction"].(map[string]any)
+	return ok && action["courseRegistrationStatus"] == "WL"
+}
+
+func waitlistAction() map[string]any {
+	return map[string]any{"class": "net.hedtech.banner.student.registration.RegistrationAction", "courseRegistrationStatus": "WL", "description": "Wait Listed", "remove": false}
+}
+
+// registrationActions returns the actions of a decoded or freshly built row.
+func registrationActions(row map[string]any) []any {
+	switch actions := row["registrationActions"].(type) {
+	case []any:
+		return actions
+	case []map[string]any:
+		var converted []any
+		for _, action := range actions {
+			converted = append(converted, action)
+		}
+		return converted
+	}
+	return nil
+}
+
+func registrationModel(section Section, status string) map[string]any {
+	return map[string]any{
+		"courseReferenceNumber":    section.CRN,
+		"term":                     section.Term,
+		"subject":                  section.Subject,
+		"courseNumber":             section.CourseNumber,
+		"sequenceNumber":           section.Sequence,
+		"courseTitle":              section.Title,
+		"campus":                   section.CampusCode,
+		"scheduleDescription":      section.ScheduleType,
+		"courseRegistrationStatus": "RW",
+		"statusDescription":        status,
+		"recordStatus":             "N",
+		"registrationActions": []map[string]any{
+			{"class": "net.hedtech.banner.student.registration.RegistrationAction", "courseRegistrationStatus": "RW", "description": "Web Registered", "remove": false},
+		},
+	}
+}
+
+func (s *Server) handleSubmitRegistration(w http.ResponseWriter, r *http.Request) {
+	batch := struct {
+		Create             []map[string]any `json:"create"`
+		Update             []map[string]any `json:"update"`
+		Destroy            []map[string]any `json:"destroy"`
+		ConditionalAddDrop bool             `json:"conditionalAddDrop"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	for _, model := range batch.Destroy {
+		crn, _ := model["courseReferenceNumber"].(string)
+		delete(s.pending, crn)
+	}
+
+	// Adds are processed before drops so that a conditional add/drop can
+	// keep the drops when any add fails.
+	var adds, drops []map[string]any
+	for _, model := range batch.Update {
+		if action, ok := model["selectedAction"].(map[string]any); ok && action["courseRegistrationStatus"] == "DW" {
+			drops = append(drops, model)
+		} else {
+			adds = append(adds, model)
+		}
+	}
+
+	inBatch := map[string]bool{}
+	for _, model := range adds {
+		crn, _ := model["courseReferenceNumber"].(string)
+		inBatch[crn] = true
+	}
+
+	var update []map[string]any
+	addFailed := false
+	for _, model := range adds {
+		crn, _ := model["courseReferenceNumber"].(string)
+		term, _ := model["term"].(string)
+		delete(s.pending, crn)
+		result := map[string]any{}
+		for key, value := range model {
+			result[key] = value
+		}
+
+		var crnErrors []map[string]string
+		section, ok := s.section(term, crn)
+		switch {
+		case !ok:
+			crnErrors = append(crnErrors, crnError("Invalid CRN"))
+		case s.CRNErrors[crn] != "":
+			crnErrors = append(crnErrors, crnError(s.CRNErrors[crn]))
+		case section.Enrolled >= section.Capacity:
+			crnErrors = append(crnErrors, crnError(fmt.Sprintf("Closed - %d Waitlisted", section.WaitCount)))
+		case !s.hasLinkedCompanion(section, inBatch):
+			crnErrors = append(crnErrors, crnError("Linked Course Required"))
+		}
+
+		if ok && isWaitlistAction(model) {
+			s.handleWaitlist(result, section)
+			update = append(update, result)
+			continue
+		}
+
+		if len(crnErrors) > 0 {
+			addFailed = true
+			result["statusDescription"] = "Errors Preventing Registration"
+			result["crnErrors"] = crnErrors
+			if ok && section.Enrolled >= section.Capacity && section.WaitCount < section.WaitCapacity {
+				result["registrationActions"] = append(registrationActions(result), waitlistAction())
+			}
+		} else {
+			s.registered[crn] = true
+			delete(s.dropped, crn)
+			s.setEnrolled(term, crn, section.Enrolled+1)
+			result["statusDescription"] = "Registered"
+			result["courseRegistrationStatus"] = "RW"
+			result["crnErrors"] = []any{}
+		}
+		update = append(update, result)
+	}
+
+	for _, model := range drops {
+		crn, _ := model["courseReferenceNumber"].(string)
+		term, _ := model["term"].(string)
+		result := map[string]any{}
+		for key, value := range model {
+			result[key] = value
+		}
+
+		section, ok := s.section(term, crn)
+		switch {
+		case !ok || !s.registered[crn]:
+			result["statusDescription"] = "Errors Preventing Registration"
+			result["crnErrors"] = []map[string]string{crnError("Not registered")}
+		case batch.ConditionalAddDrop && addFailed:
+			result["statusDescription"] = "Registered"
+			result["crnErrors"] = []map[string]string{crnError("Conditional add/drop: drop not processed because an add failed")}
+		default:
+			delete(s.registered, crn)
+			s.dropped[crn] = true
+			s.setEnrolled(term, crn, section.Enrolled-1)
+			result["statusDescription"] = "Deleted"
+			result["courseRegistrationStatus"] = "DW"
+			result["crnErrors"] = []any{}
+		}
+		update = append(update, result)
+	}
+
+	writeJSON(w, map[string]any{
+		"success": true,
+		"data": map[string]any{
+			"create":  []any{},
+			"destroy": []any{},
+			"update":  update,
+		},
+	})
+}
+
+func (s *Server) handleDegreeWorks(w http.ResponseWriter, r *http.Request, path string) {
+	if !s.hasSession(r, RoleDegreeWorks) {
+		if strings.HasPrefix(path, "/api/") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		http.Redirect(w, r, SAMLSSOPath+"?SAMLRequest="+RoleDegreeWorks, http.StatusFound)
+		return
+	}
+
+	switch path {
+	case "/worksheets/WEB31":
+		io.WriteString(w, "<html><body>DegreeWorks</body></html>")
+	case "/api/students/myself":
+		writeJSON(w, map[string]any{
+			"_embedded": map[string]any{
+				"students": []map[string]any{{
+					"id":   s.Student.ID,
+					"name": s.Student.Name,
+					"goals": []map[string]any{{
+						"school": map[string]string{"key": s.Student.School, "description": s.Student.SchoolDescription},
+						"degree": map[string]string{"key": s.Student.Degree, "description": s.Student.DegreeDescription},
+					}},
+				}},
+			},
+		})
+	case "/api/audit":
+		var classes []map[string]string
+		for _, class := range s.Audit {
+			classes = append(classes, map[string]string{
+				"termLiteralLong": class.Term,
+				"discipline":      class.Discipline,
+				"number":          class.Number,
+				"courseTitle":     class.Title,
+				"letterGrade":     class.Grade,
+				"credits":         class.Credits,
+			})
+		}
+		writeJSON(w, map[string]any{
+			"auditHeader":      map[string]string{"studentId": s.Student.ID, "studentName": s.Student.Name},
+			"classInformation": map[string]any{"classArray": classes},
+		})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) section(term string, crn string) (Section, bool) {
+	for _, section := range s.Sections {
+		if section.Term == term && section.CRN == crn {
+			return section, true
+		}
+	}
+	return Section{}, false
+}
+
+func (s *Server) setEnrolled(term string, crn string, enrolled int) {
+	for i := range s.Sections {
+		if s.Sections[i].Term == term && s.Sections[i].CRN == crn {
+			s.Sections[i].Enrolled = enrolled
+		}
+	}
+}
+
+// linkedSections returns the sections that can be taken with a linked
+// section: the other sections of the same course with a different link
+// identifier, like the labs of a lecture.
+func (s *Server) linkedSections(section Section) []Section {
+	if section.LinkIdentifier == "" {
+		return nil
+	}
+	var linked []Section
+	for _, other := range s.Sections {
+		if other.Term == section.Term && other.Subject == section.Subject && other.CourseNumber == section.CourseNumber &&
+			other.LinkIdentifier != "" && other.LinkIdentifier != section.LinkIdentifier {
+			linked = append(linked, other)
+		}
+	}
+	return linked
+}
+
+// hasLinkedCompanion reports whether one of the sections linked to section
+// is registered or added in the same batch.
+func (s *Server) hasLinkedCompanion(section Section, batch map[string]bool) bool {
+	linked := s.linkedSections(section)
+	if len(linked) == 0 && section.LinkIdentifier == "" {
+		return true
+	}
+	for _, companion := range linked {
+		if s.registered[companion.CRN] || batch[companion.CRN] {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAttribute(section Section, code string) bool {
+	for _, attribute := range section.Attributes {
+		if attribute == code {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) sectionJSON(section Section) map[string]any {
+	seats := section.Capacity - section.Enrolled
+	if seats < 0 {
+		seats = 0
+	}
+	waitAvailable := section.WaitCapacity - section.WaitCount
+	if waitAvailable < 0 {
+		waitAvailable = 0
+	}
+
+	faculty := []map[string]any{}
+	if section.Instructor != "" {
+		faculty = append(faculty, map[string]any{
+			"bannerId":              "F" + section.CRN,
+			"courseReferenceNumber": section.CRN,
+			"displayName":           section.Instructor,
+			"emailAddress":          section.InstructorEmail,
+			"primaryIndicator":      true,
+			"term":                  section.Term,
+		})
+	}
+
+	var attributes []map[string]any
+	for _, code := range section.Attributes {
+		attributes = append(attributes, map[string]any{
+			"code":                  code,
+			"courseReferenceNumber": section.CRN,
+			"description":           code,
+			"isZTCAttribute":        code == "ZTC",
+			"termCode":              section.Term,
+		})
+	}
+
+	var linkIdentifier any
+	if section.LinkIdentifier != "" {
+		linkIdentifier = section.LinkIdentifier
+	}
+
+	return map[string]any{
+		"id":                             len(section.CRN),
+		"term":                           section.Term,
+		"termDesc":                       s.termDescription(section.Term),
+		"courseReferenceNumber":          section.CRN,
+		"partOfTerm":                     "1",
+		"courseNumber":                   section.CourseNumber,
+		"subject":                        section.Subject,
+		"subjectDescription":             section.Subject,
+		"sequenceNumber":                 section.Sequence,
+		"campusDescription":              section.Campus,
+		"scheduleTypeDescription":        section.ScheduleType,
+		"courseTitle":                    section.Title,
+		"creditHours":                    section.CreditHours,
+		"maximumEnrollment":              section.Capacity,
+		"enrollment":                     section.Enrolled,
+		"seatsAvailable":                 seats,
+		"waitCapacity":                   section.WaitCapacity,
+		"waitCount":                      section.WaitCount,
+		"waitAvailable":                  waitAvailable,
+		"openSection":                    seats > 0,
+		"linkIdentifier":                 linkIdentifier,
+		"isSectionLinked":                section.LinkIdentifier != "",
+		"subjectCourse":                  section.Subject + section.CourseNumber,
+		"faculty":                        faculty,
+		"meetingsFaculty":                []map[string]any{meetingJSON(section, faculty)},
+		"sectionAttributes":              attributes,
+		"instructionalMethod":            section.InstructionalMethod,
+		"instructionalMethodDescription": section.InstructionalMethod,
+	}
+}
+
+func meetingJSON(section Section, faculty []map[string]any) map[string]any {
+	return map[string]any{
+		"category":              "01",
+		"courseReferenceNumber": section.CRN,
+		"faculty":               faculty,
+		"term":                  section.Term,
+		"meetingTime": map[string]any{
+			"beginTime":              section.Begin,
+			"endTime":                section.End,
+			"building":               section.Building,
+			"buildingDescription":    section.Building,
+			"campus":                 section.CampusCode,
+			"campusDescription":      section.Campus,
+			"courseReferenceNumber":  section.CRN,
+			"creditHourSession":      section.CreditHours,
+			"startDate":              section.StartDate,
+			"endDate":                section.EndDate,
+			"meetingType":            "CLAS",
+			"meetingTypeDescription": "Class",
+			"room":                   section.Room,
+			"term":                   section.Term,
+			"monday":                 strings.Contains(section.Days, "M"),
+			"tuesday":                strings.Contains(section.Days, "T"),
+			"wednesday":              strings.Contains(section.Days, "W"),
+			"thursday":               strings.Contains(section.Days, "R"),
+			"friday":                 strings.Contains(section.Days, "F"),
+			"saturday":               strings.Contains(section.Days, "S"),
+			"sunday":                 strings.Contains(section.Days, "U"),
+		},
+	}
+}
+
+func (s *Server) termDescription(code string) string {
+	for _, term := range s.Terms {
+		if term.Code == code {
+			return term.Description
+		}
+	}
+	return code
+}
+
+func (s *Server) anonymousSession(w http.ResponseWriter, r *http.Request) string {
+	if cookie, err := r.Cookie("JSESSIONID"); err == nil {
+		return cookie.Value
+	}
+	token := newToken()
+	http.SetCookie(w, &http.Cookie{Name: "JSESSIONID", Value: token, Path: "/"})
+	return token
+}
+
+func (s *Server) newSession(w http.ResponseWriter, role string) {
+	token := newToken()
+	s.sessions[token] = role
+	http.SetCookie(w, &http.Cookie{Name: role + "_session", Value: token, Path: "/"})
+}
+
+func (s *Server) hasSession(r *http.Request, role string) bool {
+	cookie, err := r.Cookie(role + "_session")
+	if err != nil {
+		return false
+	}
+	return s.sessions[cookie.Value] == role
+}
+
+func (s *Server) newAssertion() string {
+	token := newToken()
+	s.assertions[token] = true
+	return token
+}
+
+func (s *Server) consumeAssertion(token string) bool {
+	if !s.assertions[token] {
+		return false
+	}
+	delete(s.assertions, token)
+	return true
+}
+
+func acsPath(role string) string {
+	switch role {
+	case RoleRegistration:
+		return RegistrationACSPath
+	case RoleDegreeWorks:
+		return DegreeWorksACSPath
+	default:
+		return SSOManagerACSPath
+	}
+}
+
+func crnError(message string) map[string]string {
+	return map[string]string{
+		"class":       "net.hedtech.banner.student.registration.RegistrationMessage",
+		"errorFlag":   "F",
+		"message":     message,
+		"messageType": "DEFAULT",
+	}
+}
+
+func writeLoginPage(w http.ResponseWriter, message string) {
+	alert := ""
+	if message != "" {
+		alert = fmt.Sprintf(`<div class="alert alert-danger">%s</div>`, html.EscapeString(message))
+	}
+	fmt.Fprintf(w, `<html><body>%s<form method="post" action="%s?execution=e1s1">
+<input name="j_username" type="text"><input name="j_password" type="password">
+<button name="_eventId_proceed">Login</button></form></body></html>`, alert, LoginPath)
+}
+
+func writeAutoPost(w http.ResponseWriter, action string, values map[string]string) {
+	fmt.Fprintf(w, `<html><body onload="document.forms[0].submit()"><form method="post" action="%s">`, action)
+	for name, value := range values {
+		fmt.Fprintf(w, `<input type="hidden" name="%s" value="%s"/>`, name, html.EscapeString(value))
+	}
+	io.WriteString(w, `</form></body></html>`)
+}
+
+func writeJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("content-type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
+
+func newToken() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,393 @@
+package tasks
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	http "github.com/bogdanfinn/fhttp"
+)
+
+type ServiceProvider struct {
+	Name     string `json:"name"`
+	EntryURL string `json:"entryUrl"`
+	ACSURL   string `json:"acsUrl"`
+	ProbeURL string `json:"probeUrl,omitempty"`
+}
+
+// Authenticator runs the Shibboleth -> WSO2 commonauth -> service provider
+// SAML chain once per service provider and shares the resulting cookies
+// through the task's client. When the task has a session store, cookies from
+// an earlier run are restored and probed before logging in again.
+type Authenticator struct {
+	task              *Task
+	RelayState        string
+	SAMLRequest       string
+	SAMLResponse      string
+	samlRequestURL    string
+	commonAuthPending bool
+	authenticated     map[string]bool
+	restored          map[string]bool
+	restoreAttempted  bool
+}
+
+type samlForm struct {
+	Action       string
+	Message      string
+	SAMLRequest  string
+	SAMLResponse string
+	RelayState   string
+}
+
+func NewAuthenticator(task *Task) *Authenticator {
+	return &Authenticator{task: task, authenticated: map[string]bool{}, restored: map[string]bool{}}
+}
+
+func (a *Authenticator) Restore() {
+	a.restoreAttempted = true
+
+	session, err := a.task.RestoreSession()
+	if err != nil {
+		if err != NoSavedSession {
+			fmt.Println(err)
+		}
+		return
+	}
+	fmt.Printf("Restored session saved at %s\n", session.SavedAt.Format("2006-01-02 03:04:05 PM"))
+	for _, name := range session.Authenticated {
+		a.restored[name] = true
+	}
+}
+
+func (a *Authenticator) ProbeSession(sp ServiceProvider) error {
+	if len(sp.ProbeURL) == 0 {
+		return nil
+	}
+	fmt.Printf("Checking saved session for %s\n", sp.Name)
+
+	request, err := http.NewRequest(http.MethodGet, sp.ProbeURL, nil)
+	if err != nil {
+		return FailedToCreateRequest
+	}
+	request.Header.Add("accept", "*/*")
+	request.Header.Add("accept-language", "en-US,en;q=0.9")
+	request.Header.Add("user-agent", a.task.UserAgent)
+
+	resp, err := a.task.Client.Do(request)
+	if err != nil {
+		return FailedToMakeRequest
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return SessionInvalid
+	}
+	probeURL, err := url.Parse(sp.ProbeURL)
+	if err != nil {
+		return FailedToCreateRequest
+	}
+	if resp.Request != nil && resp.Request.URL.Host != probeURL.Host {
+		return SessionInvalid
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return FailedToReadResponseBody
+	}
+	form, err := parseSAMLForm(body)
+	if err != nil {
+		return err
+	}
+	if len(form.SAMLRequest) > 0 || len(form.SAMLResponse) > 0 {
+		return SessionInvalid
+	}
+	return nil
+}
+
+func (a *Authenticator) Save() error {
+	var authenticated []string
+	for name := range a.authenticated {
+		authenticated = append(authenticated, name)
+	}
+	sort.Strings(authenticated)
+	return a.task.SaveSession(authenticated)
+}
+
+func (a *Authenticator) VisitServiceProvider(sp ServiceProvider) error {
+	fmt.Printf("Visiting %s\n", sp.Name)
+
+	request, err := http.NewRequest(http.MethodGet, sp.EntryURL, nil)
+	if err != nil {
+		return FailedToCreateRequest
+	}
+	request.Header.Add("accept", "*/*")
+	request.Header.Add("accept-language", "en-US,en;q=0.9")
+	request.Header.Add("user-agent", a.task.UserAgent)
+
+	resp, err := a.task.Client.Do(request)
+	if err != nil {
+		return FailedToMakeRequest
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return UnknownHTTPResponseStatus
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return FailedToReadResponseBody
+	}
+	form, err := parseSAMLForm(body)
+	if err != nil {
+		return err
+	}
+
+	a.SAMLRequest = form.SAMLRequest
+	a.SAMLResponse = form.SAMLResponse
+	a.RelayState = form.RelayState
+	a.samlRequestURL = a.task.Institution.SSO.SAMLSSOURL
+	if len(a.samlRequestURL) == 0 {
+		a.samlRequestURL = form.Action
+	}
+	a.commonAuthPending = false
+	return nil
+}
+
+func (a *Authenticator) SubmitSAMLRequest() error {
+	if len(a.SAMLRequest) == 0 {
+		return nil
+	}
+	fmt.Println("Submitting SAML request")
+
+	values := url.Values{
+		"SAMLRequest": {a.SAMLRequest},
+	}
+	if len(a.RelayState) > 0 {
+		values.Set("RelayState", a.RelayState)
+	}
+
+	form, err := a.postForm(a.samlRequestURL, values)
+	if err != nil {
+		return err
+	}
+
+	a.SAMLRequest = ""
+	a.SAMLResponse = form.SAMLResponse
+	if len(form.RelayState) > 0 {
+		a.RelayState = form.RelayState
+	}
+	return nil
+}
+
+func (a *Authenticator) Login() error {
+	if len(a.SAMLResponse) > 0 {
+		return nil
+	}
+	fmt.Println("Logging in")
+
+	a.task.LoginAttempts++
+
+	values := url.Values{
+		"j_username":       {a.task.Username},
+		"j_password":       {a.task.Password},
+		"_eventId_proceed": {""},
+	}
+
+	loginURL := a.task.Institution.SSO.LoginURL
+	if strings.Contains(loginURL, "%d") {
+		loginURL = fmt.Sprintf(loginURL, a.task.LoginAttempts)
+	}
+	form, err := a.postForm(loginURL, values)
+	if err != nil {
+		return err
+	}
+
+	if err := a.task.handleLoginMessage(form.Message); err != nil {
+		return err
+	}
+	if len(form.SAMLResponse) == 0 {
+		return NoSamlResponseValue
+	}
+
+	a.RelayState = form.RelayState
+	a.SAMLResponse = form.SAMLResponse
+	a.commonAuthPending = len(a.task.Institution.SSO.CommonAuthURL) > 0
+	return nil
+}
+
+func (a *Authenticator) SubmitCommonAuth() error {
+	if !a.commonAuthPending {
+		return nil
+	}
+	fmt.Println("Submitting Common Auth SSO")
+
+	values := url.Values{
+		"RelayState":   {a.RelayState},
+		"SAMLResponse": {a.SAMLResponse},
+	}
+
+	form, err := a.postForm(a.task.Institution.SSO.CommonAuthURL, values)
+	if err != nil {
+		return err
+	}
+	if len(form.SAMLResponse) == 0 {
+		return NoSamlResponseValue
+	}
+
+	a.SAMLResponse = form.SAMLResponse
+	if len(form.RelayState) > 0 {
+		a.RelayState = form.RelayState
+	}
+	a.commonAuthPending = false
+	return nil
+}
+
+func (a *Authenticator) SubmitServiceProvider(sp ServiceProvider) error {
+	fmt.Printf("Submitting SSO to %s\n", sp.Name)
+
+	if len(a.SAMLResponse) == 0 {
+		return NoSamlResponseValue
+	}
+
+	values := url.Values{
+		"SAMLResponse": {a.SAMLResponse},
+	}
+	if len(a.RelayState) > 0 {
+		values.Set("RelayState", a.RelayState)
+	}
+
+	request, err := http.NewRequest(http.MethodPost, sp.ACSURL, bytes.NewBufferString(values.Encode()))
+	if err != nil {
+		return FailedToCreateRequest
+	}
+	request.Header.Add("accept", "*/*")
+	request.Header.Add("accept-language", "en-US,en;q=0.9")
+	request.Header.Add("content-type", "application/x-www-form-urlencoded")
+	request.Header.Add("user-agent", a.task.UserAgent)
+
+	resp, err := a.task.Client.Do(request)
+	if err != nil {
+		return FailedToMakeRequest
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return UnknownHTTPResponseStatus
+	}
+
+	a.SAMLResponse = ""
+	a.RelayState = ""
+	return nil
+}
+
+func (a *Authenticator) Authenticate(sp ServiceProvider) error {
+	if a.authenticated[sp.Name] {
+		return nil
+	}
+	if !a.restoreAttempted {
+		a.Restore()
+	}
+	if a.restored[sp.Name] {
+		delete(a.restored, sp.Name)
+		if err := a.ProbeSession(sp); err == nil {
+			fmt.Printf("Reusing saved session for %s\n", sp.Name)
+			a.authenticated[sp.Name] = true
+			return nil
+		}
+		fmt.Printf("Saved session for %s expired\n", sp.Name)
+	}
+
+	steps := []func() error{
+		func() error { return a.VisitServiceProvider(sp) },
+		a.SubmitSAMLRequest,
+		a.Login,
+		a.SubmitCommonAuth,
+		func() error { return a.SubmitServiceProvider(sp) },
+	}
+
+	for _, step := range steps {
+		if err := Retry(a.task.RetryAmount, a.task.RetryDuration, step); err != nil {
+			return MaximumAttemptsRetry
+		}
+	}
+
+	a.authenticated[sp.Name] = true
+	if err := a.Save(); err != nil {
+		fmt.Println(err)
+	}
+	return nil
+}
+
+func (a *Authenticator) Reauthenticate(providers []ServiceProvider) error {
+	a.authenticated = map[string]bool{}
+	a.restored = map[string]bool{}
+	for _, sp := range providers {
+		if err := a.Authenticate(sp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *Authenticator) postForm(target string, values url.Values) (samlForm, error) {
+	request, err := http.NewRequest(http.MethodPost, target, bytes.NewBufferString(values.Encode()))
+	if err != nil {
+		return samlForm{}, FailedToCreateRequest
+	}
+	request.Header.Add("accept", "*/*")
+	request.Header.Add("accept-language", "en-US,en;q=0.9")
+	request.Header.Add("content-type", "application/x-www-form-urlencoded")
+	request.Header.Add("user-agent", a.task.UserAgent)
+
+	resp, err := a.task.Client.Do(request)
+	if err != nil {
+		return samlForm{}, FailedToMakeRequest
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return samlForm{}, UnknownHTTPResponseStatus
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return samlForm{}, FailedToReadResponseBody
+	}
+	return parseSAMLForm(body)
+}
+
+func parseSAMLForm(body []byte) (samlForm, error) {
+	document, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return samlForm{}, UnableToGetDocument
+	}
+
+	form := samlForm{}
+	document.Find("div[class='alert alert-danger']").Each(func(index int, element *goquery.Selection) {
+		form.Message = strings.TrimSpace(element.Text())
+	})
+	document.Find("input[name='SAMLRequest']").Each(func(index int, element *goquery.Selection) {
+		if value, exists := element.Attr("value"); exists {
+			form.SAMLRequest = value
+		}
+		if action, exists := element.Closest("form").Attr("action"); exists {
+			form.Action = action
+		}
+	})
+	document.Find("input[name='SAMLResponse']").Each(func(index int, element *goquery.Selection) {
+		if value, exists := element.Attr("value"); exists {
+			form.SAMLResponse = value
+		}
+	})
+	document.Find("input[name='RelayState']").Each(func(index int, element *goquery.Selection) {
+		if value, exists := element.Attr("value"); exists {
+			form.RelayState = value
+		}
+	})
+	return form, nil
+}
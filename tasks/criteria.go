@@ -0,0 +1,196 @@
+package tasks
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// SearchCriteria narrows a class search. Filters that Banner's searchResults
+// endpoint understands are sent with the request; the rest are applied to the
+// returned sections by Matches. Campus and InstructionalMethod are only sent
+// when they are Banner codes, since Banner does not match descriptions.
+type SearchCriteria struct {
+	Subject             string
+	CourseNumber        string
+	Keyword             string
+	Title               string
+	Instructor          string
+	OpenOnly            bool
+	Campus              string
+	InstructionalMethod string
+	Attributes          []string
+	Days                string
+	StartTime           string
+	EndTime             string
+	CreditHoursMin      float64
+	CreditHoursMax      float64
+}
+
+func (c SearchCriteria) Query(term string, offset int, size int) url.Values {
+	query := url.Values{}
+	query.Set("txt_subject", c.Subject)
+	query.Set("txt_term", term)
+	if len(c.CourseNumber) > 0 {
+		query.Set("txt_courseNumber", c.CourseNumber)
+	}
+	if len(c.Keyword) > 0 {
+		query.Set("txt_keywordlike", c.Keyword)
+	}
+	if c.OpenOnly {
+		query.Set("chk_open_only", "true")
+	}
+	if isBannerCode(c.Campus) {
+		query.Set("txt_campus", c.Campus)
+	}
+	if isBannerCode(c.InstructionalMethod) {
+		query.Set("txt_instructionalMethod", c.InstructionalMethod)
+	}
+	if len(c.Attributes) > 0 {
+		query.Set("txt_attribute", c.Attributes[0])
+	}
+	if c.CreditHoursMin > 0 {
+		query.Set("txt_credithourlow", strconv.FormatFloat(c.CreditHoursMin, 'f', -1, 64))
+	}
+	if c.CreditHoursMax > 0 {
+		query.Set("txt_credithourhigh", strconv.FormatFloat(c.CreditHoursMax, 'f', -1, 64))
+	}
+	query.Set("startDatepicker", "")
+	query.Set("endDatepicker", "")
+	query.Set("pageOffset", strconv.Itoa(offset))
+	query.Set("pageMaxSize", strconv.Itoa(size))
+	query.Set("sortColumn", "subjectDescription")
+	query.Set("sortDirection", "asc")
+	return query
+}
+
+func (c SearchCriteria) Matches(section Section) bool {
+	if len(c.Title) > 0 && !containsFold(section.Title, c.Title) {
+		return false
+	}
+	if c.OpenOnly && section.SeatsAvailable <= 0 {
+		return false
+	}
+	if len(c.InstructionalMethod) > 0 && !isBannerCode(c.InstructionalMethod) &&
+		!strings.EqualFold(section.InstructionalMethod, c.InstructionalMethod) &&
+		!containsFold(section.InstructionalMethodDescription, c.InstructionalMethod) {
+		return false
+	}
+	if len(c.Campus) > 0 && !isBannerCode(c.Campus) && !section.onCampus(c.Campus) {
+		return false
+	}
+	if len(c.Instructor) > 0 && !containsFold(section.InstructorNames(), c.Instructor) {
+		return false
+	}
+	for _, attribute := range c.Attributes {
+		found := false
+		for _, sectionAttribute := range section.Attributes {
+			if strings.EqualFold(sectionAttribute.Code, attribute) || (strings.EqualFold(attribute, "ZTC") && sectionAttribute.ZTC) {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if c.CreditHoursMin > 0 && section.CreditHours < c.CreditHoursMin && section.CreditHoursHigh < c.CreditHoursMin {
+		return false
+	}
+	if c.CreditHoursMax > 0 && section.CreditHours > c.CreditHoursMax {
+		return false
+	}
+
+	for _, meeting := range section.Meetings {
+		if len(c.Days) > 0 {
+			for _, letter := range meeting.DayLetters() {
+				if !strings.ContainsRune(strings.ToUpper(c.Days), letter) {
+					return false
+				}
+			}
+		}
+		if len(c.StartTime) > 0 && meeting.Begin.Valid && meeting.Begin.Banner() < c.StartTime {
+			return false
+		}
+		if len(c.EndTime) > 0 && meeting.End.Valid && meeting.End.Banner() > c.EndTime {
+			return false
+		}
+	}
+	return true
+}
+
+func (c SearchCriteria) String() string {
+	parts := []string{c.Subject}
+	if len(c.CourseNumber) > 0 {
+		parts = append(parts, c.CourseNumber)
+	}
+	if len(c.Keyword) > 0 {
+		parts = append(parts, fmt.Sprintf("keyword %q", c.Keyword))
+	}
+	if len(c.Instructor) > 0 {
+		parts = append(parts, fmt.Sprintf("instructor %q", c.Instructor))
+	}
+	if c.OpenOnly {
+		parts = append(parts, "open only")
+	}
+	return strings.Join(parts, " ")
+}
+
+// isBannerCode reports whether value looks like a Banner code, such as the
+// campus DA or the instructional method OL, rather than a description like
+// "De Anza" or "Online".
+func isBannerCode(value string) bool {
+	if len(value) == 0 || len(value) > 5 {
+		return false
+	}
+	for _, r := range value {
+		if !unicode.IsUpper(r) && !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func ParseTimeOfDay(input string) (string, error) {
+	input = strings.ReplaceAll(strings.TrimSpace(input), ":", "")
+	if len(input) == 3 {
+		input = "0" + input
+	}
+	if _, err := time.Parse("1504", input); err != nil {
+		return "", InvalidTimeOfDay
+	}
+	return input, nil
+}
+
+func ParseCreditHours(input string) (float64, float64, error) {
+	low, high, isRange := strings.Cut(input, "-")
+	min, err := strconv.ParseFloat(strings.TrimSpace(low), 64)
+	if err != nil {
+		return 0, 0, InvalidCreditHours
+	}
+	if !isRange {
+		return min, min, nil
+	}
+	max, err := strconv.ParseFloat(strings.TrimSpace(high), 64)
+	if err != nil {
+		return 0, 0, InvalidCreditHours
+	}
+	return min, max, nil
+}
+
+func creditHours(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func containsFold(s string, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
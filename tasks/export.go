@@ -0,0 +1,97 @@
+package tasks
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+type ExportFormat string
+
+const (
+	FormatCSV    ExportFormat = "csv"
+	FormatJSON   ExportFormat = "json"
+	FormatNDJSON ExportFormat = "ndjson"
+	FormatICS    ExportFormat = "ics"
+	FormatANSI   ExportFormat = "ansi"
+	FormatHTML   ExportFormat = "html"
+	FormatSVG    ExportFormat = "svg"
+)
+
+// StdoutOutput as the Output of a task writes exports to Stdout instead of
+// a timestamped file.
+const StdoutOutput = "-"
+
+func ParseExportFormat(input string) (ExportFormat, error) {
+	switch format := ExportFormat(strings.ToLower(strings.TrimSpace(input))); format {
+	case "":
+		return FormatCSV, nil
+	case FormatCSV, FormatJSON, FormatNDJSON, FormatICS, FormatANSI, FormatHTML, FormatSVG:
+		return format, nil
+	}
+	return "", InvalidExportFormat
+}
+
+// Grid reports whether the format draws sections on a weekly grid.
+func (f ExportFormat) Grid() bool {
+	return f == FormatANSI || f == FormatHTML || f == FormatSVG
+}
+
+func (task *Task) exportFormat() ExportFormat {
+	if len(task.OutputFormat) == 0 {
+		return FormatCSV
+	}
+	return task.OutputFormat
+}
+
+// createOutput opens the destination of an export: a file named after name
+// and the current time, a path set in Output, or Stdout. Terminal grids go
+// to Stdout unless Output is set.
+func (task *Task) createOutput(name string, extension string) (io.Writer, func() error, error) {
+	if task.Output == StdoutOutput || (len(task.Output) == 0 && extension == string(FormatANSI)) {
+		stdout := task.Stdout
+		if stdout == nil {
+			stdout = os.Stdout
+		}
+		fmt.Println("Writing to stdout")
+		return stdout, func() error { return nil }, nil
+	}
+
+	fileName := task.Output
+	if len(fileName) == 0 {
+		currentTime := time.Now()
+		if len(name) > 0 {
+			name += "-"
+		}
+		fileName = fmt.Sprintf("%s%s.%s", name, currentTime.Format("2006-01-02_15-04-05"), extension)
+	}
+	file, err := os.Create(fileName)
+	if err != nil {
+		fmt.Println(err)
+		return nil, nil, FailedToWrite
+	}
+	fmt.Printf("Writing to %s\n", fileName)
+	return file, file.Close, nil
+}
+
+func writeJSONExport(w io.Writer, value any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		return FailedToWrite
+	}
+	return nil
+}
+
+func writeNDJSONExport[T any](w io.Writer, values []T) error {
+	encoder := json.NewEncoder(w)
+	for _, value := range values {
+		if err := encoder.Encode(value); err != nil {
+			return FailedToWrite
+		}
+	}
+	return nil
+}
@@ -0,0 +1,426 @@
+package tasks
+
+import (
+	"container/heap"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultScheduleCount = 5
+	// MaxScheduleCombinations bounds how many conflict-free schedules are
+	// enumerated and scored before the rest are ignored.
+	MaxScheduleCombinations = 100000
+)
+
+// Schedule score weights. A full section costs more than a preferred
+// instructor earns, so open seats win unless the user asks otherwise.
+const (
+	fullSectionPenalty       = 100
+	dayOffPenalty            = 60
+	preferredInstructorBonus = 20
+	preferredMethodBonus     = 10
+	// minutesPerPenalty is how far outside the preferred hours a meeting
+	// may start or end per point lost.
+	minutesPerPenalty = 10
+)
+
+// DesiredCourse is a course to build schedules around, such as PHYS 4A.
+type DesiredCourse struct {
+	Subject      string
+	CourseNumber string
+}
+
+func (c DesiredCourse) String() string {
+	return c.Subject + " " + c.CourseNumber
+}
+
+// ParseDesiredCourses reads a comma separated list of courses in the form
+// "PHYS 4A, MATH 1C".
+func ParseDesiredCourses(input string) ([]DesiredCourse, error) {
+	var courses []DesiredCourse
+	for _, item := range strings.Split(input, ",") {
+		fields := strings.Fields(item)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, InvalidCourse
+		}
+		courses = append(courses, DesiredCourse{
+			Subject:      strings.ToUpper(fields[0]),
+			CourseNumber: strings.ToUpper(fields[1]),
+		})
+	}
+	return courses, nil
+}
+
+// SchedulePreferences rank generated schedules. Times are in Banner's HHMM
+// form and DaysOff uses the MTWRFSU day letters.
+type SchedulePreferences struct {
+	StartTime           string
+	EndTime             string
+	DaysOff             string
+	Instructors         []string
+	InstructionalMethod string
+}
+
+// GeneratedSchedule is one conflict-free combination of sections, with the
+// CRNs ready to use as CRNSTOADD.
+type GeneratedSchedule struct {
+	Rank        int       `json:"rank"`
+	Score       int       `json:"score"`
+	CRNs        []string  `json:"crns"`
+	CreditHours float64   `json:"creditHours"`
+	Sections    []Section `json:"sections"`
+}
+
+type GenerateTask struct {
+	task      *Task
+	options   [][][]Section
+	Schedules []GeneratedSchedule
+}
+
+// GetSections searches every section of each desired course and turns them
+// into the options a schedule can use: a section on its own, or a section
+// with one of the groups of linked sections it requires.
+func (g *GenerateTask) GetSections() error {
+	if len(g.task.DesiredCourses) == 0 {
+		return InvalidCourse
+	}
+
+	g.options = nil
+	for _, course := range g.task.DesiredCourses {
+		search := *g.task
+		search.Subject = course.Subject
+		search.Subjects = nil
+		search.TermIds = []string{g.task.TermId}
+		search.Criteria.Subject = course.Subject
+		search.Criteria.CourseNumber = course.CourseNumber
+
+		searchTask := NewSearchTask(&search)
+		if err := searchTask.GetCourses(); err != nil {
+			if err == CourseSearchUnsuccessful {
+				fmt.Printf("No sections of %s found\n", course)
+				return NoSchedulesFound
+			}
+			return err
+		}
+
+		options, err := g.courseOptions(searchTask.Sections())
+		if err != nil {
+			return err
+		}
+		if len(options) == 0 {
+			fmt.Printf("No usable sections of %s found\n", course)
+			return NoSchedulesFound
+		}
+		fmt.Printf("%s: %d options\n", course, len(options))
+		g.options = append(g.options, options)
+	}
+	return nil
+}
+
+func (g *GenerateTask) courseOptions(sections []Section) ([][]Section, error) {
+	seen := map[string]bool{}
+	var options [][]Section
+	add := func(option []Section) {
+		var crns []string
+		for _, section := range option {
+			crns = append(crns, section.CRN)
+		}
+		sort.Strings(crns)
+		key := strings.Join(crns, ",")
+		if seen[key] || CheckSchedule(option).Overlaps() {
+			return
+		}
+		seen[key] = true
+		options = append(options, option)
+	}
+
+	for _, section := range sections {
+		if !section.Linked {
+			add([]Section{section})
+			continue
+		}
+		groups, err := g.task.GetLinkedSections(section.Term, section.CRN)
+		if err != nil {
+			return nil, err
+		}
+		if len(groups) == 0 {
+			add([]Section{section})
+			continue
+		}
+		for _, group := range groups {
+			add(append([]Section{section}, group...))
+		}
+	}
+	return options, nil
+}
+
+// score rates a schedule by the preferences; higher is better.
+func (p SchedulePreferences) score(sections []Section) int {
+	start := ParseBannerTime(p.StartTime)
+	end := ParseBannerTime(p.EndTime)
+	daysUsed := map[rune]bool{}
+
+	score := 0
+	for _, section := range sections {
+		if section.SeatsAvailable <= 0 {
+			score -= fullSectionPenalty
+		}
+		for _, instructor := range p.Instructors {
+			if len(instructor) > 0 && strings.Contains(strings.ToLower(section.InstructorNames()), strings.ToLower(instructor)) {
+				score += preferredInstructorBonus
+				break
+			}
+		}
+		if len(p.InstructionalMethod) > 0 &&
+			(strings.EqualFold(section.InstructionalMethod, p.InstructionalMethod) ||
+				strings.EqualFold(section.InstructionalMethodDescription, p.InstructionalMethod)) {
+			score += preferredMethodBonus
+		}
+		for _, meeting := range section.Meetings {
+			for _, letter := range meeting.DayLetters() {
+				daysUsed[letter] = true
+			}
+			if start.Valid && meeting.Begin.Valid && meeting.Begin.Minutes < start.Minutes {
+				score -= (start.Minutes - meeting.Begin.Minutes) / minutesPerPenalty
+			}
+			if end.Valid && meeting.End.Valid && meeting.End.Minutes > end.Minutes {
+				score -= (meeting.End.Minutes - end.Minutes) / minutesPerPenalty
+			}
+		}
+	}
+	for _, letter := range strings.ToUpper(p.DaysOff) {
+		if daysUsed[letter] {
+			score -= dayOffPenalty
+		}
+	}
+	return score
+}
+
+// Generate enumerates every combination of one option per course whose
+// meetings do not overlap and keeps the best ScheduleCount of them, scoring
+// each as it is found. Equal scores keep the order they were found in.
+func (g *GenerateTask) Generate() error {
+	fmt.Println("Generating schedules")
+
+	count := g.task.ScheduleCount
+	if count <= 0 {
+		count = DefaultScheduleCount
+	}
+	best := &scheduleHeap{}
+	var chosen []Section
+	found := 0
+	truncated := false
+	var walk func(course int)
+	walk = func(course int) {
+		if truncated {
+			return
+		}
+		if course == len(g.options) {
+			if found >= MaxScheduleCombinations {
+				truncated = true
+				return
+			}
+			schedule := foundSchedule{
+				GeneratedSchedule: GeneratedSchedule{Score: g.task.Preferences.score(chosen)},
+				order:             found,
+			}
+			found++
+			if best.Len() == count && !(*best)[0].worse(schedule) {
+				return
+			}
+			schedule.Sections = append([]Section(nil), chosen...)
+			schedule.CreditHours = CheckSchedule(schedule.Sections).CreditHours
+			for _, section := range schedule.Sections {
+				schedule.CRNs = append(schedule.CRNs, section.CRN)
+			}
+			if best.Len() < count {
+				heap.Push(best, schedule)
+			} else {
+				(*best)[0] = schedule
+				heap.Fix(best, 0)
+			}
+			return
+		}
+		for _, option := range g.options[course] {
+			if conflictsWith(option, chosen) {
+				continue
+			}
+			chosen = append(chosen, option...)
+			walk(course + 1)
+			chosen = chosen[:len(chosen)-len(option)]
+		}
+	}
+	walk(0)
+
+	if truncated {
+		fmt.Printf("Warning: stopped after %d schedules\n", MaxScheduleCombinations)
+	}
+	if found == 0 {
+		return NoSchedulesFound
+	}
+	fmt.Printf("Found %d conflict-free schedules\n", found)
+
+	sort.Slice(*best, func(i, j int) bool {
+		return (*best)[j].worse((*best)[i])
+	})
+	schedules := make([]GeneratedSchedule, best.Len())
+	for i, schedule := range *best {
+		schedules[i] = schedule.GeneratedSchedule
+		schedules[i].Rank = i + 1
+		fmt.Printf("#%d (score %d, %g units): CRNSTOADD=%s\n",
+			schedules[i].Rank, schedules[i].Score, schedules[i].CreditHours, strings.Join(schedules[i].CRNs, ","))
+	}
+	g.Schedules = schedules
+	return nil
+}
+
+// foundSchedule is a schedule with the order Generate found it in.
+type foundSchedule struct {
+	GeneratedSchedule
+	order int
+}
+
+// worse reports whether s ranks below other: a lower score, or the same
+// score found later.
+func (s foundSchedule) worse(other foundSchedule) bool {
+	if s.Score != other.Score {
+		return s.Score < other.Score
+	}
+	return s.order > other.order
+}
+
+// scheduleHeap holds the best schedules found so far with the worst of them
+// on top, so it can be replaced by a better one.
+type scheduleHeap []foundSchedule
+
+func (h scheduleHeap) Len() int           { return len(h) }
+func (h scheduleHeap) Less(i, j int) bool { return h[i].worse(h[j]) }
+func (h scheduleHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *scheduleHeap) Push(x any) {
+	*h = append(*h, x.(foundSchedule))
+}
+
+func (h *scheduleHeap) Pop() any {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
+
+func conflictsWith(option []Section, chosen []Section) bool {
+	for _, section := range option {
+		for _, other := range chosen {
+			if section.Conflicts(other) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (g *GenerateTask) ExportSchedules() error {
+	fmt.Println("Exporting schedules")
+
+	format := g.task.exportFormat()
+	if format == FormatICS {
+		return InvalidExportFormat
+	}
+	output, closeOutput, err := g.task.createOutput("schedules", string(format))
+	if err != nil {
+		return err
+	}
+	defer closeOutput()
+
+	switch format {
+	case FormatJSON:
+		err = writeJSONExport(output, g.Schedules)
+	case FormatNDJSON:
+		err = writeNDJSONExport(output, g.Schedules)
+	case FormatANSI, FormatHTML, FormatSVG:
+		var views []WeekView
+		for _, schedule := range g.Schedules {
+			views = append(views, WeekView{
+				Title:    fmt.Sprintf("#%d (score %d): %s", schedule.Rank, schedule.Score, strings.Join(schedule.CRNs, ",")),
+				Sections: schedule.Sections,
+			})
+		}
+		err = writeWeekViews(output, format, views)
+	default:
+		err = g.writeCSV(output)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println("Exported schedules")
+	return nil
+}
+
+func (g *GenerateTask) writeCSV(output io.Writer) error {
+	writer := csv.NewWriter(output)
+	defer writer.Flush()
+
+	header := []string{"Rank", "Score", "CRNs", "Credit Hours", "Sections", "Instructors"}
+	err := writer.Write(header)
+	if err != nil {
+		return FailedToWrite
+	}
+	for _, schedule := range g.Schedules {
+		var sections []string
+		var instructors []string
+		for _, section := range schedule.Sections {
+			sections = append(sections, fmt.Sprintf("%s %s-%s", section.Subject, section.CourseNumber, section.SequenceNumber))
+			if names := section.InstructorNames(); len(names) > 0 {
+				instructors = append(instructors, names)
+			}
+		}
+		record := []string{
+			strconv.Itoa(schedule.Rank),
+			strconv.Itoa(schedule.Score),
+			strings.Join(schedule.CRNs, ","),
+			strconv.FormatFloat(schedule.CreditHours, 'f', -1, 64),
+			strings.Join(sections, "; "),
+			strings.Join(instructors, "; "),
+		}
+		err = writer.Write(record)
+		if err != nil {
+			return FailedToWrite
+		}
+	}
+	return nil
+}
+
+func (g *GenerateTask) Run() error {
+	// Check the format before searching, since it cannot be retried.
+	if g.task.exportFormat() == FormatICS {
+		return InvalidExportFormat
+	}
+	steps := []func() error{
+		g.GetSections,
+		g.Generate,
+		g.ExportSchedules,
+	}
+
+	for _, step := range steps {
+		if err := Retry(g.task.RetryAmount, g.task.RetryDuration, step); err == InvalidExportFormat {
+			return err
+		} else if err != nil {
+			return MaximumAttemptsRetry
+		}
+	}
+
+	g.task.Client.CloseIdleConnections()
+	return nil
+}
+
+func NewGenerateTask(task *Task) *GenerateTask {
+	return &GenerateTask{task: task}
+}
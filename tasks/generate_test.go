@@ -0,0 +1,101 @@
+package tasks
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDesiredCourses(t *testing.T) {
+	courses, err := ParseDesiredCourses("PHYS 4A, math 1c,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []DesiredCourse{{Subject: "PHYS", CourseNumber: "4A"}, {Subject: "MATH", CourseNumber: "1C"}}
+	if !reflect.DeepEqual(courses, want) {
+		t.Errorf("courses = %v", courses)
+	}
+	if _, err := ParseDesiredCourses("PHYS4A"); err != InvalidCourse {
+		t.Errorf("err = %v, want InvalidCourse", err)
+	}
+}
+
+func TestGenerateTaskIncludesLinkedLab(t *testing.T) {
+	server := newMockServer(t)
+	addLinkedSections(server)
+	chdirTemp(t)
+	task := newMockTask(t, server)
+	task.OutputFormat = FormatJSON
+	task.DesiredCourses = []DesiredCourse{
+		{Subject: "PHYS", CourseNumber: "4A"},
+		{Subject: "MATH", CourseNumber: "1C"},
+		{Subject: "CHEM", CourseNumber: "1A"},
+	}
+
+	generate := NewGenerateTask(task)
+	if err := generate.Run(); err != nil {
+		t.Fatal(err)
+	}
+	// 30001 overlaps the CHEM lecture, 40002 overlaps 30002 and 40003
+	// overlaps its own lecture.
+	if len(generate.Schedules) != 1 {
+		t.Fatalf("schedules = %+v", generate.Schedules)
+	}
+	if crns := strings.Join(generate.Schedules[0].CRNs, ","); crns != "30002,30003,40001,40004" {
+		t.Errorf("crns = %s", crns)
+	}
+	if generate.Schedules[0].CreditHours != 16 {
+		t.Errorf("credit hours = %g", generate.Schedules[0].CreditHours)
+	}
+}
+
+func TestGenerateTaskRanksByPreferences(t *testing.T) {
+	server := newMockServer(t)
+	chdirTemp(t)
+	task := newMockTask(t, server)
+	task.DesiredCourses = []DesiredCourse{{Subject: "PHYS", CourseNumber: "4A"}}
+
+	generate := NewGenerateTask(task)
+	if err := generate.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(generate.Schedules) != 2 || generate.Schedules[0].CRNs[0] != "30001" {
+		t.Fatalf("open section did not rank first: %+v", generate.Schedules)
+	}
+
+	task.Preferences = SchedulePreferences{DaysOff: "MW"}
+	if err := generate.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	if generate.Schedules[0].CRNs[0] != "30002" {
+		t.Errorf("schedule meeting on days off ranked first: %+v", generate.Schedules[0].CRNs)
+	}
+	task.ScheduleCount = 1
+	if err := generate.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	if len(generate.Schedules) != 1 || generate.Schedules[0].CRNs[0] != "30002" || generate.Schedules[0].Rank != 1 {
+		t.Errorf("did not keep only the best schedule: %+v", generate.Schedules)
+	}
+}
+
+func TestGenerateTaskRejectsICS(t *testing.T) {
+	server := newMockServer(t)
+	searches := 0
+	server.BeforeRequest = func(r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/searchResults/searchResults") {
+			searches++
+		}
+	}
+	task := newMockTask(t, server)
+	task.OutputFormat = FormatICS
+	task.DesiredCourses = []DesiredCourse{{Subject: "PHYS", CourseNumber: "4A"}}
+
+	if err := NewGenerateTask(task).Run(); err != InvalidExportFormat {
+		t.Errorf("err = %v, want InvalidExportFormat", err)
+	}
+	if searches != 0 {
+		t.Errorf("searched %d times before rejecting the format", searches)
+	}
+}
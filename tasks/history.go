@@ -0,0 +1,96 @@
+package tasks
+
+import (
+	"fmt"
+	"time"
+)
+
+const DefaultHistorySince = 7 * 24 * time.Hour
+
+type HistoryTask struct {
+	task      *Task
+	History   map[string][]Snapshot
+	Reopened  []Reopening
+	startedAt time.Time
+}
+
+func (h *HistoryTask) since() time.Time {
+	since := h.task.HistorySince
+	if since <= 0 {
+		since = DefaultHistorySince
+	}
+	return h.startedAt.Add(-since)
+}
+
+func (h *HistoryTask) GetFillRates() error {
+	for _, crn := range h.task.CoursesToAdd {
+		if len(crn) == 0 {
+			continue
+		}
+		snapshots, err := h.task.Snapshots.History(h.task.TermId, crn, h.since())
+		if err != nil {
+			return err
+		}
+		h.History[crn] = snapshots
+
+		fmt.Printf("Fill rate of %s since %s\n", crn, h.since().Format("2006-01-02 15:04"))
+		if len(snapshots) == 0 {
+			fmt.Println("No snapshots recorded")
+		}
+		for _, snapshot := range snapshots {
+			fmt.Printf("%s  %d/%d (%.0f%%)  %d seats  %d waitlist seats\n",
+				snapshot.RecordedAt.Format("2006-01-02 15:04"),
+				snapshot.Enrollment,
+				snapshot.MaximumEnrollment,
+				snapshot.FillRate()*100,
+				snapshot.SeatsAvailable,
+				snapshot.WaitAvailable,
+			)
+		}
+	}
+	return nil
+}
+
+func (h *HistoryTask) GetReopened() error {
+	reopened, err := h.task.Snapshots.Reopened(h.since())
+	if err != nil {
+		return err
+	}
+	h.Reopened = reopened
+
+	fmt.Printf("%d sections went from full to open since %s\n", len(reopened), h.since().Format("2006-01-02 15:04"))
+	for _, reopening := range reopened {
+		fmt.Printf("%s  %s %s %s-%s %s  %d seats\n",
+			reopening.OpenAt.Format("2006-01-02 15:04"),
+			reopening.CRN,
+			reopening.Subject,
+			reopening.CourseNumber,
+			reopening.SequenceNumber,
+			reopening.Title,
+			reopening.SeatsAvailable,
+		)
+	}
+	return nil
+}
+
+func (h *HistoryTask) Run() error {
+	if h.task.Snapshots == nil {
+		return NoSnapshotStore
+	}
+	h.startedAt = time.Now()
+
+	steps := []func() error{
+		h.GetFillRates,
+		h.GetReopened,
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func NewHistoryTask(task *Task) *HistoryTask {
+	return &HistoryTask{task: task, History: map[string][]Snapshot{}}
+}
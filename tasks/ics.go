@@ -0,0 +1,200 @@
+package tasks
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	icsDateTimeLayout = "20060102T150405"
+	icsLineLimit      = 75
+)
+
+var icsWeekdays = map[time.Weekday]string{
+	time.Sunday:    "SU",
+	time.Monday:    "MO",
+	time.Tuesday:   "TU",
+	time.Wednesday: "WE",
+	time.Thursday:  "TH",
+	time.Friday:    "FR",
+	time.Saturday:  "SA",
+}
+
+type icsWriter struct {
+	w   io.Writer
+	err error
+}
+
+// line writes a content line, folding it at 75 octets as RFC 5545 requires.
+func (i *icsWriter) line(name string, value string) {
+	if i.err != nil {
+		return
+	}
+	content := name + ":" + value
+	var folded strings.Builder
+	width := 0
+	for _, r := range content {
+		size := len(string(r))
+		if width+size > icsLineLimit {
+			folded.WriteString("\r\n ")
+			width = 1
+		}
+		folded.WriteRune(r)
+		width += size
+	}
+	folded.WriteString("\r\n")
+	_, i.err = io.WriteString(i.w, folded.String())
+}
+
+func icsText(value string) string {
+	replacer := strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	)
+	return replacer.Replace(value)
+}
+
+// WriteICS writes the meetings of sections as weekly recurring events in an
+// iCalendar file. Meetings without days, times or dates, such as
+// asynchronous online classes, are skipped.
+func WriteICS(w io.Writer, sections []Section, location *time.Location) error {
+	if location == nil {
+		location = time.UTC
+	}
+	ics := &icsWriter{w: w}
+	stamp := time.Now().UTC().Format(icsDateTimeLayout) + "Z"
+
+	var first, last time.Time
+	for _, section := range sections {
+		for _, meeting := range section.Meetings {
+			if !meeting.Scheduled() {
+				continue
+			}
+			if first.IsZero() || meeting.StartDate.Before(first) {
+				first = meeting.StartDate
+			}
+			if meeting.EndDate.After(last) {
+				last = meeting.EndDate
+			}
+		}
+	}
+
+	ics.line("BEGIN", "VCALENDAR")
+	ics.line("VERSION", "2.0")
+	ics.line("PRODID", "-//Veil//Class Schedule//EN")
+	ics.line("CALSCALE", "GREGORIAN")
+	ics.line("METHOD", "PUBLISH")
+	if !first.IsZero() {
+		writeICSTimezone(ics, location, first, last)
+	}
+
+	for _, section := range sections {
+		for i, meeting := range section.Meetings {
+			if !meeting.Scheduled() {
+				continue
+			}
+			start := meeting.FirstOccurrence(location)
+			end := time.Date(start.Year(), start.Month(), start.Day(), meeting.End.Hour(), meeting.End.Minute(), 0, 0, location)
+			until := time.Date(meeting.EndDate.Year(), meeting.EndDate.Month(), meeting.EndDate.Day(), 23, 59, 59, 0, location)
+
+			var days []string
+			for _, day := range meeting.Days {
+				days = append(days, icsWeekdays[day])
+			}
+
+			description := fmt.Sprintf("CRN %s\n%s", section.CRN, section.TermDescription)
+			instructors := meeting.Instructors
+			if len(instructors) == 0 {
+				instructors = section.Instructors
+			}
+			for _, instructor := range instructors {
+				description += "\nInstructor: " + instructor.Name
+				if len(instructor.Email) > 0 {
+					description += " <" + instructor.Email + ">"
+				}
+			}
+			if len(meeting.TypeDescription) > 0 {
+				description += "\n" + meeting.TypeDescription
+			}
+
+			ics.line("BEGIN", "VEVENT")
+			ics.line("UID", fmt.Sprintf("%s-%s-%d@veil", section.Term, section.CRN, i))
+			ics.line("DTSTAMP", stamp)
+			ics.line("DTSTART;TZID="+location.String(), start.Format(icsDateTimeLayout))
+			ics.line("DTEND;TZID="+location.String(), end.Format(icsDateTimeLayout))
+			ics.line("RRULE", fmt.Sprintf("FREQ=WEEKLY;BYDAY=%s;UNTIL=%sZ", strings.Join(days, ","), until.UTC().Format(icsDateTimeLayout)))
+			ics.line("SUMMARY", icsText(fmt.Sprintf("%s %s-%s %s", section.Subject, section.CourseNumber, section.SequenceNumber, section.Title)))
+			if place := meeting.Location(); len(place) > 0 {
+				ics.line("LOCATION", icsText(place))
+			}
+			ics.line("DESCRIPTION", icsText(description))
+			ics.line("END", "VEVENT")
+		}
+	}
+	ics.line("END", "VCALENDAR")
+
+	if ics.err != nil {
+		return FailedToWrite
+	}
+	return nil
+}
+
+// writeICSTimezone describes location with the offset changes between the
+// start of the year of from and the end of the year of until, so calendar
+// apps keep classes at the same wall clock time across daylight saving.
+func writeICSTimezone(ics *icsWriter, location *time.Location, from time.Time, until time.Time) {
+	ics.line("BEGIN", "VTIMEZONE")
+	ics.line("TZID", location.String())
+
+	current := time.Date(from.Year(), time.January, 1, 0, 0, 0, 0, location)
+	end := time.Date(until.Year()+1, time.January, 1, 0, 0, 0, 0, location)
+	name, offset := current.Zone()
+	writeICSObservance(ics, current, current.IsDST(), name, offset, offset)
+
+	for day := current.AddDate(0, 0, 1); day.Before(end); day = day.AddDate(0, 0, 1) {
+		if _, dayOffset := day.Zone(); dayOffset == offset {
+			continue
+		}
+		low, high := day.AddDate(0, 0, -1).Unix(), day.Unix()
+		for high-low > 1 {
+			middle := (low + high) / 2
+			if _, middleOffset := time.Unix(middle, 0).In(location).Zone(); middleOffset == offset {
+				low = middle
+			} else {
+				high = middle
+			}
+		}
+		transition := time.Unix(high, 0).In(location)
+		newName, newOffset := transition.Zone()
+		writeICSObservance(ics, transition, transition.IsDST(), newName, offset, newOffset)
+		offset = newOffset
+	}
+	ics.line("END", "VTIMEZONE")
+}
+
+func writeICSObservance(ics *icsWriter, start time.Time, dst bool, name string, from int, to int) {
+	component := "STANDARD"
+	if dst {
+		component = "DAYLIGHT"
+	}
+	ics.line("BEGIN", component)
+	ics.line("DTSTART", start.In(time.FixedZone("", from)).Format(icsDateTimeLayout))
+	ics.line("TZOFFSETFROM", icsOffset(from))
+	ics.line("TZOFFSETTO", icsOffset(to))
+	ics.line("TZNAME", name)
+	ics.line("END", component)
+}
+
+func icsOffset(seconds int) string {
+	sign := "+"
+	if seconds < 0 {
+		sign = "-"
+		seconds = -seconds
+	}
+	return fmt.Sprintf("%s%02d%02d", sign, seconds/3600, seconds%3600/60)
+}
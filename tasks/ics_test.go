@@ -0,0 +1,92 @@
+package tasks
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteICS(t *testing.T) {
+	location, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skip(err)
+	}
+	sections := []Section{
+		{
+			Term: "202432", TermDescription: "2024 Winter De Anza", CRN: "30001", Subject: "PHYS", CourseNumber: "4A",
+			SequenceNumber: "01", Title: "PHYSICS FOR SCIENTISTS, ENGINEERS",
+			Instructors: []Instructor{{Name: "Newton, Isaac", Email: "newton@example.edu"}},
+			Meetings: []Meeting{
+				{
+					Days:      []time.Weekday{time.Tuesday, time.Thursday},
+					Begin:     ParseBannerTime("0930"),
+					End:       ParseBannerTime("1120"),
+					StartDate: time.Date(2024, time.January, 8, 0, 0, 0, 0, time.UTC),
+					EndDate:   time.Date(2024, time.March, 29, 0, 0, 0, 0, time.UTC),
+					Building:  "S",
+					Room:      "S16",
+				},
+				{TypeDescription: "Online asynchronous"},
+			},
+		},
+	}
+
+	output := &bytes.Buffer{}
+	if err := WriteICS(output, sections, location); err != nil {
+		t.Fatal(err)
+	}
+	ics := output.String()
+
+	for _, line := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"TZID:America/Los_Angeles\r\n",
+		"BEGIN:DAYLIGHT\r\nDTSTART:20240310T020000\r\nTZOFFSETFROM:-0800\r\nTZOFFSETTO:-0700\r\n",
+		"UID:202432-30001-0@veil\r\n",
+		"DTSTART;TZID=America/Los_Angeles:20240109T093000\r\n",
+		"DTEND;TZID=America/Los_Angeles:20240109T112000\r\n",
+		"RRULE:FREQ=WEEKLY;BYDAY=TU,TH;UNTIL=20240330T065959Z\r\n",
+		"SUMMARY:PHYS 4A-01 PHYSICS FOR SCIENTISTS\\, ENGINEERS\r\n",
+		"LOCATION:S S16\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(ics, line) {
+			t.Errorf("missing %q in\n%s", line, ics)
+		}
+	}
+	if strings.Count(ics, "BEGIN:VEVENT") != 1 {
+		t.Errorf("want one event for the scheduled meeting:\n%s", ics)
+	}
+	for _, line := range strings.Split(ics, "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line longer than 75 octets: %q", line)
+		}
+	}
+}
+
+func TestSignupTaskExportsSchedule(t *testing.T) {
+	server := newMockServer(t)
+	task := newMockTask(t, server)
+	dir := chdirTemp(t)
+	task.CoursesToAdd = []string{"30001"}
+	task.OutputFormat = FormatICS
+
+	signup := NewSignupTask(task)
+	if err := signup.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(signup.Registered) != 1 || len(signup.Registered[0].Meetings) != 1 {
+		t.Fatalf("unexpected registered sections %+v", signup.Registered)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "schedule-*.ics"))
+	if len(files) != 1 {
+		t.Fatalf("got %d ics files, want 1", len(files))
+	}
+	data, _ := os.ReadFile(files[0])
+	if !strings.Contains(string(data), "RRULE:FREQ=WEEKLY;BYDAY=MO,WE;") || !strings.Contains(string(data), "Newton") {
+		t.Errorf("unexpected schedule:\n%s", data)
+	}
+}
@@ -0,0 +1,153 @@
+package tasks
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Institution describes the Banner 9 / DegreeWorks deployment of a college:
+// where its services live, how its SSO chain is wired and how it builds
+// term codes.
+type Institution struct {
+	Name            string         `json:"name"`
+	RegistrationURL string         `json:"registrationUrl"`
+	DegreeWorksURL  string         `json:"degreeWorksUrl"`
+	Timezone        string         `json:"timezone"`
+	SSO             SSOConfig      `json:"sso"`
+	TermCode        TermCodeScheme `json:"termCode"`
+}
+
+type SSOConfig struct {
+	LoginURL      string            `json:"loginUrl"`
+	CommonAuthURL string            `json:"commonAuthUrl"`
+	SAMLSSOURL    string            `json:"samlSsoUrl"`
+	Hosts         []string          `json:"hosts"`
+	Registration  []ServiceProvider `json:"registration"`
+	DegreeWorks   []ServiceProvider `json:"degreeWorks"`
+}
+
+// TermCodeScheme builds a Banner term code by substituting {year},
+// {quarter} and {campus} in Format.
+type TermCodeScheme struct {
+	Format      string            `json:"format"`
+	Campuses    map[string]string `json:"campuses"`
+	Quarters    map[string]string `json:"quarters"`
+	YearOffsets map[string]int    `json:"yearOffsets,omitempty"`
+}
+
+var FHDA = &Institution{
+	Name:            "Foothill-De Anza Community College District",
+	RegistrationURL: "https://reg-prod.ec.fhda.edu/StudentRegistrationSsb",
+	DegreeWorksURL:  "https://dw-prod.ec.fhda.edu/responsiveDashboard",
+	Timezone:        "America/Los_Angeles",
+	SSO: SSOConfig{
+		LoginURL:      "https://ssoshib.fhda.edu/idp/profile/SAML2/Redirect/SSO?execution=e1s%d",
+		CommonAuthURL: "https://eis-prod.ec.fhda.edu/commonauth",
+		SAMLSSOURL:    "https://eis-prod.ec.fhda.edu/samlsso",
+		Hosts: []string{
+			"ssoshib.fhda.edu",
+			"eis-prod.ec.fhda.edu",
+		},
+		Registration: []ServiceProvider{
+			{
+				Name:     "ssomanager",
+				EntryURL: "https://ssb-prod.ec.fhda.edu/ssomanager/saml/login?relayState=%2Fc%2Fauth%2FSSB%3Fpkg%3Dhttps%3A%2F%2Fssb-prod.ec.fhda.edu%2FPROD%2Ffhda_uportal.P_DeepLink_Post%3Fp_page%3Dbwskfreg.P_AltPin%26p_payload%3De30%3D",
+				ACSURL:   "https://ssb-prod.ec.fhda.edu/ssomanager/saml/SSO",
+			},
+			{
+				Name:     "registration",
+				EntryURL: "https://reg-prod.ec.fhda.edu/StudentRegistrationSsb/ssb/registration/registerPostSignIn?mode=registration",
+				ACSURL:   "https://reg-prod.ec.fhda.edu/StudentRegistrationSsb/saml/SSO/alias/registrationssb-prod-sp",
+				ProbeURL: "https://reg-prod.ec.fhda.edu/StudentRegistrationSsb/ssb/registration/registerPostSignIn?mode=registration",
+			},
+		},
+		DegreeWorks: []ServiceProvider{
+			{
+				Name:     "degreeworks",
+				EntryURL: "https://dw-prod.ec.fhda.edu/responsiveDashboard/worksheets/WEB31",
+				ACSURL:   "https://dw-prod.ec.fhda.edu/responsiveDashboard/saml/SSO",
+				ProbeURL: "https://dw-prod.ec.fhda.edu/responsiveDashboard/api/students/myself",
+			},
+		},
+	},
+	TermCode: TermCodeScheme{
+		Format: "{year}{quarter}{campus}",
+		Campuses: map[string]string{
+			"fh": "1",
+			"da": "2",
+		},
+		Quarters: map[string]string{
+			"summer": "1",
+			"fall":   "2",
+			"winter": "3",
+			"spring": "4",
+		},
+	},
+}
+
+func LoadInstitution(path string) (*Institution, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, FailedLoadingInstitution
+	}
+
+	institution := Institution{}
+	if err := json.Unmarshal(data, &institution); err != nil {
+		fmt.Println(err)
+		return nil, UnableToParseJSON
+	}
+	if err := institution.Validate(); err != nil {
+		return nil, err
+	}
+	return &institution, nil
+}
+
+func (i *Institution) Validate() error {
+	if len(i.RegistrationURL) == 0 {
+		return fmt.Errorf("%w: missing registrationUrl", InvalidInstitution)
+	}
+	if len(i.SSO.LoginURL) == 0 {
+		return fmt.Errorf("%w: missing sso.loginUrl", InvalidInstitution)
+	}
+	if len(i.TermCode.Format) == 0 {
+		return fmt.Errorf("%w: missing termCode.format", InvalidInstitution)
+	}
+	return nil
+}
+
+func (i *Institution) BuildTermId(year int, campus string, quarter string) (string, error) {
+	campusCode, ok1 := i.TermCode.Campuses[campus]
+	if !ok1 && strings.Contains(i.TermCode.Format, "{campus}") {
+		return "", InvalidCampus
+	}
+	quarterCode, ok2 := i.TermCode.Quarters[quarter]
+	if !ok2 {
+		return "", InvalidQuarter
+	}
+	replacer := strings.NewReplacer(
+		"{year}", strconv.Itoa(year+i.TermCode.YearOffsets[quarter]),
+		"{quarter}", quarterCode,
+		"{campus}", campusCode,
+	)
+	return replacer.Replace(i.TermCode.Format), nil
+}
+
+func (i *Institution) Location() *time.Location {
+	location, err := time.LoadLocation(i.Timezone)
+	if err != nil {
+		return time.Local
+	}
+	return location
+}
+
+func (i *Institution) Registration(path string) string {
+	return strings.TrimSuffix(i.RegistrationURL, "/") + path
+}
+
+func (i *Institution) DegreeWorks(path string) string {
+	return strings.TrimSuffix(i.DegreeWorksURL, "/") + path
+}
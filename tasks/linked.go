@@ -0,0 +1,153 @@
+package tasks
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	http "github.com/bogdanfinn/fhttp"
+)
+
+// GetLinkedSections returns the groups of sections Banner accepts alongside
+// crn, such as the labs of a lecture. Each group has to be registered
+// together with crn; an unlinked section has no groups.
+func (task *Task) GetLinkedSections(termId string, crn string) ([][]Section, error) {
+	url := task.Institution.Registration(fmt.Sprintf(
+		"/ssb/searchResults/fetchLinkedSections?term=%s&courseReferenceNumber=%s",
+		termId, crn,
+	))
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, FailedToCreateRequest
+	}
+	request.Header.Add("accept", "application/json")
+	request.Header.Add("accept-language", "en-US,en;q=0.9")
+	request.Header.Add("user-agent", task.UserAgent)
+
+	resp, err := task.Client.Do(request)
+	if err != nil {
+		return nil, FailedToMakeRequest
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, FailedToReadResponseBody
+	}
+	if err := task.checkSession(resp, body); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		return nil, FailedGettingLinkedSections
+	}
+
+	linked := LinkedSections{}
+	if err := json.Unmarshal(body, &linked); err != nil {
+		fmt.Println(err)
+		return nil, UnableToParseJSON
+	}
+
+	var groups [][]Section
+	for _, rawGroup := range linked.LinkedData {
+		var group []Section
+		for _, raw := range rawGroup {
+			group = append(group, NewSection(raw))
+		}
+		if len(group) > 0 {
+			groups = append(groups, group)
+		}
+	}
+	return groups, nil
+}
+
+// pickLinkedGroup chooses the companions of section. A group the user listed
+// in CoursesToAdd wins; otherwise the first group with open seats that does
+// not clash with section, then the first one without a clash, then the first.
+func pickLinkedGroup(section Section, groups [][]Section, wanted map[string]bool) []Section {
+	for _, group := range groups {
+		chosen := true
+		for _, companion := range group {
+			if !wanted[companion.CRN] {
+				chosen = false
+			}
+		}
+		if chosen {
+			return group
+		}
+	}
+
+	compatible := func(group []Section, needSeats bool) bool {
+		for i, companion := range group {
+			if needSeats && companion.SeatsAvailable <= 0 {
+				return false
+			}
+			if companion.Conflicts(section) {
+				return false
+			}
+			for _, other := range group[i+1:] {
+				if companion.Conflicts(other) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	for _, needSeats := range []bool{true, false} {
+		for _, group := range groups {
+			if compatible(group, needSeats) {
+				return group
+			}
+		}
+	}
+	return groups[0]
+}
+
+// ResolveLinkedSections groups every CRN to add with the linked sections it
+// needs, so each group is added and submitted together.
+func (s *SignupTask) ResolveLinkedSections() error {
+	fmt.Println("Resolving linked sections")
+
+	wanted := map[string]bool{}
+	for _, crn := range s.coursesToAdd() {
+		wanted[strings.TrimSpace(crn)] = true
+	}
+
+	s.Groups = nil
+	grouped := map[string]bool{}
+	for _, crn := range s.coursesToAdd() {
+		crn = strings.TrimSpace(crn)
+		if len(crn) == 0 || grouped[crn] {
+			continue
+		}
+		grouped[crn] = true
+
+		groups, err := s.task.GetLinkedSections(s.task.TermId, crn)
+		if err == SessionExpired {
+			return err
+		}
+		if err != nil {
+			fmt.Printf("Could not get linked sections of %s: %s\n", crn, err)
+		}
+		if len(groups) == 0 {
+			s.Groups = append(s.Groups, []string{crn})
+			continue
+		}
+
+		section := Section{Term: s.task.TermId, CRN: crn}
+		if err := s.task.LoadMeetings(&section); err != nil {
+			fmt.Println(err)
+		}
+
+		group := []string{crn}
+		var companions []string
+		for _, companion := range pickLinkedGroup(section, groups, wanted) {
+			group = append(group, companion.CRN)
+			companions = append(companions, fmt.Sprintf("%s (%s %s-%s)", companion.CRN, companion.ScheduleType, companion.CourseNumber, companion.SequenceNumber))
+			grouped[companion.CRN] = true
+		}
+		fmt.Printf("Adding %s with %s\n", crn, strings.Join(companions, ", "))
+		s.Groups = append(s.Groups, group)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package tasks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/veil/mock"
+)
+
+func addLinkedSections(server *mock.Server) {
+	server.Sections = append(server.Sections,
+		mock.Section{Term: "202432", CRN: "40001", Subject: "CHEM", CourseNumber: "1A", Sequence: "01", Title: "GENERAL CHEMISTRY", ScheduleType: "Lecture", CreditHours: 5, Capacity: 60, Enrolled: 30, Days: "MW", Begin: "1000", End: "1120", StartDate: "01/08/2024", EndDate: "03/29/2024", LinkIdentifier: "L1"},
+		mock.Section{Term: "202432", CRN: "40002", Subject: "CHEM", CourseNumber: "1A", Sequence: "01L", Title: "GENERAL CHEMISTRY", ScheduleType: "Laboratory", Capacity: 20, Enrolled: 20, Days: "R", Begin: "1400", End: "1650", StartDate: "01/08/2024", EndDate: "03/29/2024", LinkIdentifier: "A1"},
+		mock.Section{Term: "202432", CRN: "40003", Subject: "CHEM", CourseNumber: "1A", Sequence: "02L", Title: "GENERAL CHEMISTRY", ScheduleType: "Laboratory", Capacity: 20, Enrolled: 5, Days: "M", Begin: "1000", End: "1250", StartDate: "01/08/2024", EndDate: "03/29/2024", LinkIdentifier: "A1"},
+		mock.Section{Term: "202432", CRN: "40004", Subject: "CHEM", CourseNumber: "1A", Sequence: "03L", Title: "GENERAL CHEMISTRY", ScheduleType: "Laboratory", Capacity: 20, Enrolled: 5, Days: "F", Begin: "1300", End: "1550", StartDate: "01/08/2024", EndDate: "03/29/2024", LinkIdentifier: "A1"},
+	)
+}
+
+func TestSignupTaskAutoPicksLinkedSection(t *testing.T) {
+	server := newMockServer(t)
+	addLinkedSections(server)
+	task := newMockTask(t, server)
+	task.CoursesToAdd = []string{"40001"}
+
+	signup := NewSignupTask(task)
+	if err := signup.Run(); err != nil {
+		t.Fatal(err)
+	}
+	// 40002 is full and 40003 clashes with the lecture.
+	if !server.Registered("40001") || !server.Registered("40004") {
+		t.Errorf("registered 40001 = %v, 40004 = %v", server.Registered("40001"), server.Registered("40004"))
+	}
+	if len(signup.Groups) != 1 || len(signup.Groups[0]) != 2 {
+		t.Errorf("groups = %v", signup.Groups)
+	}
+}
+
+func TestSignupTaskUsesChosenLinkedSection(t *testing.T) {
+	server := newMockServer(t)
+	addLinkedSections(server)
+	task := newMockTask(t, server)
+	// 40003 clashes with the lecture, but the user asked for it.
+	task.CoursesToAdd = []string{"40001", "40003"}
+	task.AllowConflicts = true
+
+	if err := NewSignupTask(task).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if !server.Registered("40001") || !server.Registered("40003") || server.Registered("40004") {
+		t.Error("did not register the chosen lab 40003 with 40001")
+	}
+}
+
+func TestSignupTaskKeepsLinkedGroupTogether(t *testing.T) {
+	server := newMockServer(t)
+	addLinkedSections(server)
+	server.AddErrors["40004"] = "Section is not available for registration"
+	task := newMockTask(t, server)
+	task.RetryAmount = 1
+	task.CoursesToAdd = []string{"40001", "30003"}
+
+	signup := NewSignupTask(task)
+	if err := signup.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if server.Registered("40001") {
+		t.Error("40001 was registered without its lab")
+	}
+	if server.Pending("40001") {
+		t.Error("40001 was left in the cart without its lab")
+	}
+	if !server.Registered("30003") {
+		t.Error("30003 was not registered")
+	}
+	if result, _ := signup.result("40001"); result.Status != NotAddedStatus || len(result.Errors) != 1 {
+		t.Errorf("40001 result = %+v", result)
+	}
+}
+
+func TestSignupTaskSwapsIntoLinkedSection(t *testing.T) {
+	server := newMockServer(t)
+	addLinkedSections(server)
+	server.Register("30003")
+	task := newMockTask(t, server)
+	task.CoursesToSwap = []Swap{{Drop: "30003", Add: "40001"}}
+
+	if err := NewSignupTask(task).Swap(); err != nil {
+		t.Fatal(err)
+	}
+	if server.Registered("30003") || !server.Registered("40001") || !server.Registered("40004") {
+		t.Errorf("registered 30003 = %v, 40001 = %v, 40004 = %v, want swapped with the lab",
+			server.Registered("30003"), server.Registered("40001"), server.Registered("40004"))
+	}
+}
+
+func TestMeetingOverlaps(t *testing.T) {
+	startDate, _ := time.Parse(BannerDateLayout, "01/08/2024")
+	endDate, _ := time.Parse(BannerDateLayout, "03/29/2024")
+	meeting := func(begin string, end string, days ...time.Weekday) Meeting {
+		return Meeting{Days: days, Begin: ParseBannerTime(begin), End: ParseBannerTime(end), StartDate: startDate, EndDate: endDate}
+	}
+
+	a := meeting("0900", "1000", time.Monday, time.Wednesday)
+	if !a.Overlaps(meeting("0930", "1030", time.Wednesday)) {
+		t.Error("MW 9-10 should overlap W 9:30-10:30")
+	}
+	if a.Overlaps(meeting("0900", "1000", time.Tuesday, time.Thursday)) {
+		t.Error("MW should not overlap TR")
+	}
+	if a.Overlaps(meeting("1000", "1100", time.Monday)) {
+		t.Error("back to back meetings should not overlap")
+	}
+}
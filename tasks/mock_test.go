@@ -0,0 +1,99 @@
+package tasks
+
+import (
+	"os"
+	"testing"
+
+	tls_client "github.com/bogdanfinn/tls-client"
+	"github.com/bogdanfinn/tls-client/profiles"
+	"github.com/veil/mock"
+)
+
+func newMockServer(t *testing.T) *mock.Server {
+	t.Helper()
+	server := mock.NewServer()
+	server.Terms = []mock.Term{{Code: "202432", Description: "2024 Winter De Anza"}}
+	server.Sections = []mock.Section{
+		{Term: "202432", CRN: "30001", Subject: "PHYS", CourseNumber: "4A", Sequence: "01", Title: "PHYSICS FOR SCIENTISTS", Campus: "De Anza", CampusCode: "DA", ScheduleType: "Lecture", CreditHours: 6, Capacity: 40, Enrolled: 12, WaitCapacity: 10, Instructor: "Newton, Isaac", Days: "MW", Begin: "0930", End: "1120", StartDate: "01/08/2024", EndDate: "03/29/2024", Building: "S", Room: "S16", InstructionalMethod: "In Person"},
+		{Term: "202432", CRN: "30002", Subject: "PHYS", CourseNumber: "4A", Sequence: "02", Title: "PHYSICS FOR SCIENTISTS", Campus: "De Anza", CampusCode: "DA", ScheduleType: "Lecture", CreditHours: 6, Capacity: 40, Enrolled: 40, WaitCapacity: 10, WaitCount: 3, Instructor: "Curie, Marie", Days: "TR", Begin: "1330", End: "1520", StartDate: "01/08/2024", EndDate: "03/29/2024", Building: "S", Room: "S18", InstructionalMethod: "In Person"},
+		{Term: "202432", CRN: "30003", Subject: "MATH", CourseNumber: "1C", Sequence: "01", Title: "CALCULUS", Campus: "De Anza", CampusCode: "DA", ScheduleType: "Lecture", CreditHours: 5, Capacity: 35, Enrolled: 20, Instructor: "Gauss, Carl", Days: "MTWR", Begin: "0830", End: "0920", StartDate: "01/08/2024", EndDate: "03/29/2024", Building: "MQ", Room: "MQ1", InstructionalMethod: "In Person"},
+	}
+	server.Student = mock.Student{ID: "20000000", Name: "Ada Lovelace", School: "CC", SchoolDescription: "Community College", Degree: "AA", DegreeDescription: "Associate in Arts"}
+	server.Audit = []mock.AuditClass{
+		{Term: "Fall 2023", Discipline: "MATH", Number: "1B", Title: "CALCULUS", Grade: "A", Credits: "5"},
+		{Term: "Fall 2023", Discipline: "EWRT", Number: "1A", Title: "COMPOSITION", Grade: "B+", Credits: "5"},
+	}
+	t.Cleanup(server.Close)
+	return server
+}
+
+func mockInstitution(server *mock.Server) *Institution {
+	return &Institution{
+		Name:            "Mock College",
+		RegistrationURL: server.RegistrationURL(),
+		DegreeWorksURL:  server.DegreeWorksURL(),
+		Timezone:        "America/Los_Angeles",
+		SSO: SSOConfig{
+			LoginURL:      server.LoginURL(),
+			CommonAuthURL: server.URL + mock.CommonAuthPath,
+			SAMLSSOURL:    server.URL + mock.SAMLSSOPath,
+			Registration: []ServiceProvider{
+				{
+					Name:     "ssomanager",
+					EntryURL: server.URL + mock.SSOManagerPath + "/saml/login",
+					ACSURL:   server.URL + mock.SSOManagerACSPath,
+				},
+				{
+					Name:     "registration",
+					EntryURL: server.RegistrationURL() + "/ssb/registration/registerPostSignIn?mode=registration",
+					ACSURL:   server.URL + mock.RegistrationACSPath,
+					ProbeURL: server.RegistrationURL() + "/ssb/registration/registerPostSignIn?mode=registration",
+				},
+			},
+			DegreeWorks: []ServiceProvider{
+				{
+					Name:     "degreeworks",
+					EntryURL: server.DegreeWorksURL() + "/worksheets/WEB31",
+					ACSURL:   server.URL + mock.DegreeWorksACSPath,
+					ProbeURL: server.DegreeWorksURL() + "/api/students/myself",
+				},
+			},
+		},
+		TermCode: FHDA.TermCode,
+	}
+}
+
+func newMockTask(t *testing.T, server *mock.Server) *Task {
+	t.Helper()
+	client, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(),
+		tls_client.WithClientProfile(profiles.Chrome_117),
+		tls_client.WithCookieJar(tls_client.NewCookieJar()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Task{
+		Client:      client,
+		UserAgent:   "veil-test",
+		Username:    server.Username,
+		Password:    server.Password,
+		Subject:     "PHYS",
+		TermId:      "202432",
+		RetryAmount: 2,
+		Institution: mockInstitution(server),
+	}
+}
+
+func chdirTemp(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	return dir
+}
@@ -0,0 +1,332 @@
+package tasks
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	http "github.com/bogdanfinn/fhttp"
+)
+
+const (
+	// WaitlistedRegistration and DroppedRegistration are the statuses of a
+	// Registration on a waitlist or dropped; registered ones have
+	// RegisteredStatus.
+	WaitlistedRegistration = "Waitlisted"
+	DroppedRegistration    = "Dropped"
+	// registrationEventLayout is the local date and time of Banner's schedule
+	// calendar events.
+	registrationEventLayout = "2006-01-02T15:04:05"
+)
+
+// Registration is one section of the student's registration history for a
+// term, with its meetings when the student is registered in it.
+type Registration struct {
+	Section
+	Status       string `json:"status"`
+	WaitPosition int    `json:"waitPosition,omitempty"`
+}
+
+type ScheduleTask struct {
+	task          *Task
+	Registrations []Registration
+}
+
+// registrationStatus maps the registration status code of a record onto the
+// Registered, Waitlisted and Dropped statuses of a Registration. Codes it
+// does not know keep Banner's description.
+func registrationStatus(record RegistrationRecord) string {
+	code := record.CourseRegistrationStatus
+	switch {
+	case code == WaitlistAction:
+		return WaitlistedRegistration
+	case strings.HasPrefix(code, "D"):
+		return DroppedRegistration
+	case strings.HasPrefix(code, "R"):
+		return RegisteredStatus
+	}
+	return record.StatusDescription
+}
+
+// GetRegistrationHistory resets the registration history page to the term,
+// which lists every registration of the student in it.
+func (s *ScheduleTask) GetRegistrationHistory() error {
+	fmt.Println("Getting registration history")
+
+	url := s.task.Institution.Registration(fmt.Sprintf(
+		"/ssb/registrationHistory/reset?term=%s",
+		s.task.TermId,
+	))
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return FailedToCreateRequest
+	}
+	request.Header.Add("accept", "application/json")
+	request.Header.Add("accept-language", "en-US,en;q=0.9")
+	request.Header.Add("user-agent", s.task.UserAgent)
+
+	resp, err := s.task.Client.Do(request)
+	if err != nil {
+		return FailedToMakeRequest
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return FailedToReadResponseBody
+	}
+	if err := s.task.checkSession(resp, body); err != nil {
+		return err
+	}
+	if resp.StatusCode != 200 {
+		return FailedGettingHistory
+	}
+
+	history := RegistrationHistory{}
+	if err := json.Unmarshal(body, &history); err != nil {
+		fmt.Println(err)
+		return UnableToParseJSON
+	}
+	if !history.Success {
+		return FailedGettingHistory
+	}
+
+	s.Registrations = nil
+	for _, record := range history.Data.Registrations {
+		s.Registrations = append(s.Registrations, Registration{
+			Section: Section{
+				Term:           record.Term,
+				CRN:            record.CourseReferenceNumber,
+				Subject:        record.Subject,
+				CourseNumber:   record.CourseNumber,
+				SequenceNumber: record.SequenceNumber,
+				Title:          record.CourseTitle,
+				Campus:         record.Campus,
+				ScheduleType:   record.ScheduleDescription,
+				CreditHours:    record.CreditHour,
+			},
+			Status:       registrationStatus(record),
+			WaitPosition: intValue(record.WaitPosition),
+		})
+	}
+	fmt.Printf("Found %d registrations\n", len(s.Registrations))
+	return nil
+}
+
+// eventMeetings turns schedule calendar events, one per class meeting of the
+// term, back into the weekly meetings of each CRN.
+func eventMeetings(events []RegistrationEvent) map[string][]Meeting {
+	meetings := map[string][]Meeting{}
+	for _, event := range events {
+		start, err1 := time.Parse(registrationEventLayout, event.Start)
+		end, err2 := time.Parse(registrationEventLayout, event.End)
+		if err1 != nil || err2 != nil || event.AllDay {
+			continue
+		}
+		date := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+		begin := TimeOfDay{Minutes: start.Hour()*60 + start.Minute(), Valid: true}
+		finish := TimeOfDay{Minutes: end.Hour()*60 + end.Minute(), Valid: true}
+
+		crnMeetings := meetings[event.CRN]
+		found := -1
+		for i, meeting := range crnMeetings {
+			if meeting.Begin == begin && meeting.End == finish {
+				found = i
+			}
+		}
+		if found < 0 {
+			crnMeetings = append(crnMeetings, Meeting{Begin: begin, End: finish, StartDate: date, EndDate: date})
+			found = len(crnMeetings) - 1
+		}
+		meeting := &crnMeetings[found]
+		if date.Before(meeting.StartDate) {
+			meeting.StartDate = date
+		}
+		if date.After(meeting.EndDate) {
+			meeting.EndDate = date
+		}
+		hasDay := false
+		for _, day := range meeting.Days {
+			if day == date.Weekday() {
+				hasDay = true
+			}
+		}
+		if !hasDay {
+			meeting.Days = append(meeting.Days, date.Weekday())
+			sort.Slice(meeting.Days, func(i, j int) bool { return meeting.Days[i] < meeting.Days[j] })
+		}
+		meetings[event.CRN] = crnMeetings
+	}
+	return meetings
+}
+
+// GetRegistrationEvents reads the schedule calendar of the term set by
+// GetRegistrationHistory and fills in the meetings of the registered
+// sections.
+func (s *ScheduleTask) GetRegistrationEvents() error {
+	fmt.Println("Getting registration events")
+
+	request, err := http.NewRequest(http.MethodGet, s.task.Institution.Registration("/ssb/classRegistration/getRegistrationEvents?termFilter="), nil)
+	if err != nil {
+		return FailedToCreateRequest
+	}
+	request.Header.Add("accept", "application/json")
+	request.Header.Add("accept-language", "en-US,en;q=0.9")
+	request.Header.Add("user-agent", s.task.UserAgent)
+
+	resp, err := s.task.Client.Do(request)
+	if err != nil {
+		return FailedToMakeRequest
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return FailedToReadResponseBody
+	}
+	if err := s.task.checkSession(resp, body); err != nil {
+		return err
+	}
+	if resp.StatusCode != 200 {
+		return FailedGettingEvents
+	}
+
+	var events []RegistrationEvent
+	if err := json.Unmarshal(body, &events); err != nil {
+		fmt.Println(err)
+		return UnableToParseJSON
+	}
+
+	meetings := eventMeetings(events)
+	var units float64
+	for i := range s.Registrations {
+		registration := &s.Registrations[i]
+		registration.Meetings = meetings[registration.CRN]
+
+		var times []string
+		for _, meeting := range registration.Meetings {
+			times = append(times, fmt.Sprintf("%s %s", meeting.DayLetters(), blockTimes(meeting)))
+		}
+		line := fmt.Sprintf("%s %s %s: %s, %g units", registration.CRN, blockLabel(registration.Section), registration.Title,
+			registration.Status, registration.CreditHours)
+		if len(times) > 0 {
+			line += ", " + strings.Join(times, ", ")
+		}
+		fmt.Println(line)
+		if registration.Status == RegisteredStatus {
+			units += registration.CreditHours
+		}
+	}
+	fmt.Printf("Registered for %g units\n", units)
+	return nil
+}
+
+// Registered returns the sections the student is registered in.
+func (s *ScheduleTask) Registered() []Section {
+	var sections []Section
+	for _, registration := range s.Registrations {
+		if registration.Status == RegisteredStatus {
+			sections = append(sections, registration.Section)
+		}
+	}
+	return sections
+}
+
+func (s *ScheduleTask) ExportRegistrations() error {
+	fmt.Println("Exporting registrations")
+
+	format := s.task.exportFormat()
+	output, closeOutput, err := s.task.createOutput("registrations", string(format))
+	if err != nil {
+		return err
+	}
+	defer closeOutput()
+
+	switch format {
+	case FormatJSON:
+		err = writeJSONExport(output, s.Registrations)
+	case FormatNDJSON:
+		err = writeNDJSONExport(output, s.Registrations)
+	case FormatICS:
+		err = WriteICS(output, s.Registered(), s.task.Institution.Location())
+	case FormatANSI, FormatHTML, FormatSVG:
+		err = writeWeekViews(output, format, []WeekView{{Title: "Registered schedule " + s.task.TermId, Sections: s.Registered()}})
+	default:
+		err = s.writeCSV(output)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println("Exported registrations")
+	return nil
+}
+
+func (s *ScheduleTask) writeCSV(output io.Writer) error {
+	writer := csv.NewWriter(output)
+	defer writer.Flush()
+
+	header := []string{
+		"Term", "Course Reference Number", "Subject", "Course Number", "Sequence Number", "Course Title", "Status",
+		"Waitlist Position", "Credit Hours", "Days", "Begin Time", "End Time", "Start Date", "End Date",
+	}
+	err := writer.Write(header)
+	if err != nil {
+		return FailedToWrite
+	}
+	for _, registration := range s.Registrations {
+		meetings := registration.Meetings
+		if len(meetings) == 0 {
+			meetings = []Meeting{{}}
+		}
+		for _, meeting := range meetings {
+			waitPosition := ""
+			if registration.WaitPosition > 0 {
+				waitPosition = strconv.Itoa(registration.WaitPosition)
+			}
+			record := []string{
+				registration.Term,
+				registration.CRN,
+				registration.Subject,
+				registration.CourseNumber,
+				registration.SequenceNumber,
+				registration.Title,
+				registration.Status,
+				waitPosition,
+				strconv.FormatFloat(registration.CreditHours, 'f', -1, 64),
+				meeting.DayLetters(),
+				meeting.Begin.String(),
+				meeting.End.String(),
+				formatBannerDate(meeting.StartDate),
+				formatBannerDate(meeting.EndDate),
+			}
+			err = writer.Write(record)
+			if err != nil {
+				return FailedToWrite
+			}
+		}
+	}
+	return nil
+}
+
+func (s *ScheduleTask) Run() error {
+	steps := []func() error{
+		s.GetRegistrationHistory,
+		s.GetRegistrationEvents,
+		s.ExportRegistrations,
+	}
+	if err := s.task.RunAuthenticated(s.task.Institution.SSO.Registration, s.GetRegistrationHistory, steps); err != nil {
+		return err
+	}
+
+	s.task.Client.CloseIdleConnections()
+	return nil
+}
+
+func NewScheduleTask(task *Task) *ScheduleTask {
+	return &ScheduleTask{task: task}
+}
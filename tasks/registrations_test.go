@@ -0,0 +1,65 @@
+package tasks
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestScheduleTaskReadsRegistrations(t *testing.T) {
+	server := newMockServer(t)
+	server.Register("30001")
+	server.Register("30003")
+	task := newMockTask(t, server)
+	task.CoursesToAdd = []string{"30002"}
+	task.CoursesToDrop = []string{"30003"}
+	task.AllowWaitlist = true
+	if err := NewSignupTask(task).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewSignupTask(task).Drop(); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := chdirTemp(t)
+	task.OutputFormat = FormatJSON
+	task.Output = "registrations.json"
+	schedule := NewScheduleTask(task)
+	if err := schedule.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses := map[string]string{}
+	for _, registration := range schedule.Registrations {
+		statuses[registration.CRN] = registration.Status
+	}
+	if statuses["30001"] != RegisteredStatus || statuses["30002"] != WaitlistedRegistration || statuses["30003"] != DroppedRegistration {
+		t.Errorf("statuses = %v", statuses)
+	}
+
+	registered := schedule.Registered()
+	if len(registered) != 1 || registered[0].CreditHours != 6 || len(registered[0].Meetings) != 1 {
+		t.Fatalf("registered = %+v", registered)
+	}
+	meeting := registered[0].Meetings[0]
+	if meeting.DayLetters() != "MW" || meeting.Begin.Banner() != "0930" || meeting.End.Banner() != "1120" {
+		t.Errorf("meeting = %s %s-%s", meeting.DayLetters(), meeting.Begin.Banner(), meeting.End.Banner())
+	}
+	if formatBannerDate(meeting.StartDate) != "01/08/2024" || meeting.EndDate.Weekday() != time.Wednesday {
+		t.Errorf("meeting dates = %s - %s", meeting.StartDate, meeting.EndDate)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "registrations.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var exported []Registration
+	if err := json.Unmarshal(data, &exported); err != nil {
+		t.Fatal(err)
+	}
+	if len(exported) != 3 {
+		t.Errorf("exported %d registrations, want 3", len(exported))
+	}
+}
@@ -0,0 +1,345 @@
+package tasks
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	// gridSlot is the height of one row of the terminal grid.
+	gridSlot = 30
+	// terminalColumn is the width of a day in the terminal grid.
+	terminalColumn = 14
+
+	svgGutter    = 64
+	svgColumn    = 120
+	svgHeader    = 24
+	svgTitle     = 28
+	svgMinutePx  = 1
+	svgViewSpace = 24
+)
+
+var gridDays = []time.Weekday{
+	time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday,
+}
+
+// Section colors, cycled through in the order sections are listed.
+var (
+	gridColors     = []string{"#4e79a7", "#f28e2b", "#59a14f", "#b07aa1", "#76b7b2", "#edc948", "#ff9da7", "#9c755f", "#e15759", "#bab0ac"}
+	terminalColors = []string{"\x1b[97;44m", "\x1b[30;43m", "\x1b[30;42m", "\x1b[97;45m", "\x1b[30;46m", "\x1b[30;103m", "\x1b[30;105m", "\x1b[30;47m", "\x1b[97;41m", "\x1b[30;102m"}
+)
+
+const (
+	terminalConflict = "\x1b[97;101m"
+	terminalReset    = "\x1b[0m"
+)
+
+// WeekView is a titled set of sections drawn as one weekly grid, such as a
+// generated schedule or the sections a student is registered in.
+type WeekView struct {
+	Title    string
+	Sections []Section
+}
+
+// gridBlock is one meeting of a section on one day of the grid.
+type gridBlock struct {
+	color    int
+	section  Section
+	meeting  Meeting
+	day      time.Weekday
+	conflict bool
+}
+
+func (v WeekView) blocks() []gridBlock {
+	var blocks []gridBlock
+	for i, section := range v.Sections {
+		for _, meeting := range section.Meetings {
+			if !meeting.Scheduled() {
+				continue
+			}
+			for _, day := range meeting.Days {
+				blocks = append(blocks, gridBlock{color: i, section: section, meeting: meeting, day: day})
+			}
+		}
+	}
+	for i := range blocks {
+		for j := range blocks {
+			if i != j && blocks[i].day == blocks[j].day && blocks[i].meeting.Overlaps(blocks[j].meeting) {
+				blocks[i].conflict = true
+			}
+		}
+	}
+	return blocks
+}
+
+// unscheduled returns the sections with a meeting that has no set days or
+// times, which the grid cannot show.
+func (v WeekView) unscheduled() []Section {
+	var sections []Section
+	for _, section := range v.Sections {
+		for _, meeting := range section.Meetings {
+			if !meeting.Scheduled() {
+				sections = append(sections, section)
+				break
+			}
+		}
+		if len(section.Meetings) == 0 {
+			sections = append(sections, section)
+		}
+	}
+	return sections
+}
+
+// gridHours returns the whole hours the grid spans so every view shares one
+// scale, defaulting to 8 AM to 5 PM.
+func gridHours(views []WeekView) (int, int) {
+	first, last := -1, -1
+	for _, view := range views {
+		for _, block := range view.blocks() {
+			if begin := block.meeting.Begin.Hour(); first < 0 || begin < first {
+				first = begin
+			}
+			end := (block.meeting.End.Minutes + 59) / 60
+			if end > last {
+				last = end
+			}
+		}
+	}
+	if first < 0 {
+		return 8, 17
+	}
+	return first, last
+}
+
+func blockLabel(section Section) string {
+	return fmt.Sprintf("%s %s-%s", section.Subject, section.CourseNumber, section.SequenceNumber)
+}
+
+func blockTimes(meeting Meeting) string {
+	return fmt.Sprintf("%s-%s", meeting.Begin, meeting.End)
+}
+
+// stickyWriter keeps the first write error so rendering code can write
+// without checking every call.
+type stickyWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (s *stickyWriter) printf(format string, args ...any) {
+	if s.err != nil {
+		return
+	}
+	_, s.err = fmt.Fprintf(s.w, format, args...)
+}
+
+// WriteTerminal draws each view as a Monday to Sunday grid of half hour rows
+// colored with ANSI escape codes. Overlapping meetings are drawn in red, with
+// the CRNs that share a row.
+func WriteTerminal(w io.Writer, views []WeekView) error {
+	out := &stickyWriter{w: w}
+	first, last := gridHours(views)
+
+	for _, view := range views {
+		blocks := view.blocks()
+		if len(view.Title) > 0 {
+			out.printf("%s\n", view.Title)
+		}
+		out.printf("%8s", "")
+		for _, day := range gridDays {
+			out.printf(" %-*s", terminalColumn-1, day.String()[:3])
+		}
+		out.printf("\n")
+
+		for slot := first * 60; slot < last*60; slot += gridSlot {
+			if slot%60 == 0 {
+				out.printf("%8s", TimeOfDay{Minutes: slot, Valid: true})
+			} else {
+				out.printf("%8s", "")
+			}
+			for _, day := range gridDays {
+				cell := strings.Repeat(" ", terminalColumn-1)
+				var found []gridBlock
+				for _, block := range blocks {
+					if block.day == day && block.meeting.Begin.Minutes < slot+gridSlot && block.meeting.End.Minutes > slot {
+						found = append(found, block)
+					}
+				}
+				if len(found) == 0 {
+					out.printf(" %s", cell)
+					continue
+				}
+				text := ""
+				if len(found) > 1 {
+					var crns []string
+					for _, block := range found {
+						crns = append(crns, block.section.CRN)
+					}
+					text = strings.Join(crns, "/")
+				} else {
+					switch row := (slot - found[0].meeting.Begin.Minutes/gridSlot*gridSlot) / gridSlot; row {
+					case 0:
+						text = blockLabel(found[0].section)
+					case 1:
+						text = found[0].meeting.Begin.String()
+					case 2:
+						text = found[0].meeting.Location()
+					}
+				}
+				// Cut by runes so names are not split inside a character.
+				if runes := []rune(text); len(runes) > terminalColumn-1 {
+					text = string(runes[:terminalColumn-1])
+				}
+				color := terminalColors[found[0].color%len(terminalColors)]
+				if found[0].conflict {
+					color = terminalConflict
+				}
+				out.printf(" %s%-*s%s", color, terminalColumn-1, text, terminalReset)
+			}
+			out.printf("\n")
+		}
+
+		for i, section := range view.Sections {
+			out.printf("%s  %s %s %s %s\n", terminalColors[i%len(terminalColors)], terminalReset, section.CRN, blockLabel(section), section.Title)
+		}
+		for _, section := range view.unscheduled() {
+			out.printf("No set meeting time: %s %s\n", section.CRN, blockLabel(section))
+		}
+		out.printf("\n")
+	}
+
+	if out.err != nil {
+		return FailedToWrite
+	}
+	return nil
+}
+
+func svgHeight(first int, last int) int {
+	return svgTitle + svgHeader + (last-first)*60*svgMinutePx
+}
+
+// writeSVGGrid draws one view at vertical offset top.
+func writeSVGGrid(out *stickyWriter, view WeekView, top int, first int, last int) {
+	out.printf(`<text x="0" y="%d" font-size="16" font-weight="bold">%s</text>`+"\n", top+18, html.EscapeString(view.Title))
+	gridTop := top + svgTitle + svgHeader
+	gridHeight := (last - first) * 60 * svgMinutePx
+
+	for i, day := range gridDays {
+		x := svgGutter + i*svgColumn
+		out.printf(`<text x="%d" y="%d" font-size="13" text-anchor="middle">%s</text>`+"\n", x+svgColumn/2, gridTop-8, day.String()[:3])
+		out.printf(`<rect x="%d" y="%d" width="%d" height="%d" fill="none" stroke="#ccc"/>`+"\n", x, gridTop, svgColumn, gridHeight)
+	}
+	for hour := first; hour <= last; hour++ {
+		y := gridTop + (hour-first)*60*svgMinutePx
+		out.printf(`<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#e5e5e5"/>`+"\n", svgGutter, y, svgGutter+len(gridDays)*svgColumn, y)
+		if hour < last {
+			out.printf(`<text x="%d" y="%d" font-size="11" text-anchor="end" fill="#666">%s</text>`+"\n", svgGutter-6, y+11, TimeOfDay{Minutes: hour * 60, Valid: true})
+		}
+	}
+
+	for _, block := range view.blocks() {
+		column := 0
+		for i, day := range gridDays {
+			if day == block.day {
+				column = i
+			}
+		}
+		x := svgGutter + column*svgColumn + 2
+		y := gridTop + (block.meeting.Begin.Minutes-first*60)*svgMinutePx
+		height := (block.meeting.End.Minutes - block.meeting.Begin.Minutes) * svgMinutePx
+		stroke := "none"
+		if block.conflict {
+			stroke = "#d00"
+		}
+		out.printf(`<g><title>%s</title>`+"\n", html.EscapeString(fmt.Sprintf("%s %s %s\n%s %s", block.section.CRN, blockLabel(block.section), block.section.Title, blockTimes(block.meeting), block.meeting.Location())))
+		out.printf(`<rect x="%d" y="%d" width="%d" height="%d" rx="4" fill="%s" fill-opacity="0.85" stroke="%s" stroke-width="2"/>`+"\n",
+			x, y, svgColumn-4, height, gridColors[block.color%len(gridColors)], stroke)
+		lines := []string{blockLabel(block.section), blockTimes(block.meeting), block.meeting.Location()}
+		for i, line := range lines {
+			if len(line) == 0 || (i+1)*13 > height {
+				continue
+			}
+			out.printf(`<text x="%d" y="%d" font-size="11" fill="#fff">%s</text>`+"\n", x+4, y+(i+1)*13, html.EscapeString(line))
+		}
+		out.printf("</g>\n")
+	}
+}
+
+func writeSVG(out *stickyWriter, views []WeekView) {
+	first, last := gridHours(views)
+	width := svgGutter + len(gridDays)*svgColumn + 2
+	height := len(views)*(svgHeight(first, last)+svgViewSpace) - svgViewSpace + 2
+
+	out.printf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif">`+"\n", width, height, width, height)
+	out.printf(`<rect width="100%%" height="100%%" fill="#fff"/>` + "\n")
+	for i, view := range views {
+		writeSVGGrid(out, view, i*(svgHeight(first, last)+svgViewSpace), first, last)
+	}
+	out.printf("</svg>\n")
+}
+
+// WriteSVG draws the views as Monday to Sunday grids stacked in one SVG
+// image. Overlapping meetings are outlined in red.
+func WriteSVG(w io.Writer, views []WeekView) error {
+	out := &stickyWriter{w: w}
+	writeSVG(out, views)
+	if out.err != nil {
+		return FailedToWrite
+	}
+	return nil
+}
+
+// WriteHTML writes a standalone page with the SVG grid of the views and a
+// table of their sections.
+func WriteHTML(w io.Writer, views []WeekView) error {
+	out := &stickyWriter{w: w}
+	out.printf("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>Veil Schedule</title>\n")
+	out.printf("<style>body{font-family:sans-serif;margin:24px}table{border-collapse:collapse;margin:16px 0 32px}td,th{border:1px solid #ddd;padding:4px 8px;text-align:left}.swatch{display:inline-block;width:12px;height:12px;border-radius:2px}</style>\n")
+	out.printf("</head>\n<body>\n")
+	writeSVG(out, views)
+
+	for _, view := range views {
+		out.printf("<h2>%s</h2>\n<table>\n", html.EscapeString(view.Title))
+		out.printf("<tr><th></th><th>CRN</th><th>Course</th><th>Title</th><th>Meetings</th><th>Instructors</th><th>Seats</th></tr>\n")
+		for i, section := range view.Sections {
+			var meetings []string
+			for _, meeting := range section.Meetings {
+				if meeting.Scheduled() {
+					meetings = append(meetings, fmt.Sprintf("%s %s %s", meeting.DayLetters(), blockTimes(meeting), meeting.Location()))
+				} else if len(meeting.TypeDescription) > 0 {
+					meetings = append(meetings, meeting.TypeDescription)
+				}
+			}
+			out.printf("<tr><td><span class=\"swatch\" style=\"background:%s\"></span></td><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%d</td></tr>\n",
+				gridColors[i%len(gridColors)],
+				html.EscapeString(section.CRN),
+				html.EscapeString(blockLabel(section)),
+				html.EscapeString(section.Title),
+				html.EscapeString(strings.Join(meetings, "; ")),
+				html.EscapeString(section.InstructorNames()),
+				section.SeatsAvailable)
+		}
+		out.printf("</table>\n")
+	}
+	out.printf("</body>\n</html>\n")
+
+	if out.err != nil {
+		return FailedToWrite
+	}
+	return nil
+}
+
+// writeWeekViews renders views in one of the grid formats.
+func writeWeekViews(w io.Writer, format ExportFormat, views []WeekView) error {
+	switch format {
+	case FormatHTML:
+		return WriteHTML(w, views)
+	case FormatSVG:
+		return WriteSVG(w, views)
+	default:
+		return WriteTerminal(w, views)
+	}
+}
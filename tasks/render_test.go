@@ -0,0 +1,111 @@
+package tasks
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func renderSections() []Section {
+	startDate := time.Date(2024, time.January, 8, 0, 0, 0, 0, time.UTC)
+	endDate := time.Date(2024, time.March, 29, 0, 0, 0, 0, time.UTC)
+	return []Section{
+		{
+			CRN: "30001", Subject: "PHYS", CourseNumber: "4A", SequenceNumber: "01", Title: "PHYSICS & LAB",
+			Meetings: []Meeting{{Days: []time.Weekday{time.Monday, time.Wednesday}, Begin: ParseBannerTime("0930"), End: ParseBannerTime("1120"), StartDate: startDate, EndDate: endDate, Building: "S", Room: "S16"}},
+		},
+		{
+			CRN: "30003", Subject: "MATH", CourseNumber: "1C", SequenceNumber: "01", Title: "CALCULUS",
+			Meetings: []Meeting{{Days: []time.Weekday{time.Wednesday}, Begin: ParseBannerTime("1100"), End: ParseBannerTime("1150"), StartDate: startDate, EndDate: endDate}},
+		},
+		{
+			CRN: "30004", Subject: "CS", CourseNumber: "1B", SequenceNumber: "61Z", Title: "ONLINE",
+			Meetings: []Meeting{{TypeDescription: "Online asynchronous"}},
+		},
+	}
+}
+
+func TestWriteTerminal(t *testing.T) {
+	output := &bytes.Buffer{}
+	if err := WriteTerminal(output, []WeekView{{Title: "Plan", Sections: renderSections()}}); err != nil {
+		t.Fatal(err)
+	}
+	grid := output.String()
+	for _, text := range []string{"Mon", "Sun", "9:00 AM", "PHYS 4A-01", terminalConflict, "30001/30003", "No set meeting time: 30004 CS 1B-61Z"} {
+		if !strings.Contains(grid, text) {
+			t.Errorf("missing %q in\n%s", text, grid)
+		}
+	}
+	if strings.Contains(grid, "8:00 AM") || strings.Contains(grid, "12:00 PM") {
+		t.Errorf("grid does not span 9 AM to noon:\n%s", grid)
+	}
+}
+
+func TestWriteTerminalCutsNamesByRune(t *testing.T) {
+	sections := renderSections()[:1]
+	sections[0].Meetings[0].Building = "Müller-Lüdenscheidt"
+	sections[0].Meetings[0].Room = ""
+
+	output := &bytes.Buffer{}
+	if err := WriteTerminal(output, []WeekView{{Title: "Plan", Sections: sections}}); err != nil {
+		t.Fatal(err)
+	}
+	if !utf8.Valid(output.Bytes()) {
+		t.Errorf("grid is not valid UTF-8:\n%s", output)
+	}
+	if !strings.Contains(output.String(), "Müller-Lüdens"+terminalReset) {
+		t.Errorf("location not cut to %d runes:\n%s", terminalColumn-1, output)
+	}
+}
+
+func TestWriteSVG(t *testing.T) {
+	output := &bytes.Buffer{}
+	views := []WeekView{{Title: "#1", Sections: renderSections()}, {Title: "#2", Sections: renderSections()[:1]}}
+	if err := WriteSVG(output, views); err != nil {
+		t.Fatal(err)
+	}
+	svg := output.String()
+
+	decoder := xml.NewDecoder(strings.NewReader(svg))
+	for {
+		if _, err := decoder.Token(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("invalid SVG: %s\n%s", err, svg)
+		}
+	}
+	// Three meetings in the first view and two in the second.
+	if count := strings.Count(svg, `rx="4"`); count != 5 {
+		t.Errorf("%d meeting blocks, want 5", count)
+	}
+	if !strings.Contains(svg, "PHYSICS &amp; LAB") || !strings.Contains(svg, `stroke="#d00"`) {
+		t.Errorf("missing title or conflict outline:\n%s", svg)
+	}
+}
+
+func TestSearchTaskExportsHTML(t *testing.T) {
+	server := newMockServer(t)
+	dir := chdirTemp(t)
+	task := newMockTask(t, server)
+	task.OutputFormat = FormatHTML
+	task.Output = "search.html"
+
+	if err := NewSearchTask(task).Run(); err != nil {
+		t.Fatal(err)
+	}
+	page, err := os.ReadFile(filepath.Join(dir, "search.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, text := range []string{"<!DOCTYPE html>", "<svg", "30001", "Newton, Isaac"} {
+		if !bytes.Contains(page, []byte(text)) {
+			t.Errorf("missing %q in\n%s", text, page)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package tasks
+
+import (
+	"fmt"
+	"time"
+)
+
+// MinTravelTime is the shortest break that still leaves time to get from a
+// class on one campus to a class on another.
+const MinTravelTime = 30 * time.Minute
+
+const (
+	// OverlapConflict is two meetings at the same time.
+	OverlapConflict = "overlap"
+	// TravelConflict is two meetings on different campuses with less than
+	// MinTravelTime between them.
+	TravelConflict = "travel"
+)
+
+type Conflict struct {
+	Kind         string
+	Section      Section
+	Meeting      Meeting
+	Other        Section
+	OtherMeeting Meeting
+}
+
+func (c Conflict) String() string {
+	describe := func(section Section, meeting Meeting) string {
+		name := section.CRN
+		if len(section.Subject) > 0 {
+			name = fmt.Sprintf("%s %s %s-%s", section.CRN, section.Subject, section.CourseNumber, section.SequenceNumber)
+		}
+		return fmt.Sprintf("%s (%s %s-%s)", name, meeting.DayLetters(), meeting.Begin, meeting.End)
+	}
+	if c.Kind == TravelConflict {
+		return fmt.Sprintf("%s and %s are back to back on different campuses",
+			describe(c.Section, c.Meeting), describe(c.Other, c.OtherMeeting))
+	}
+	return fmt.Sprintf("%s overlaps %s", describe(c.Section, c.Meeting), describe(c.Other, c.OtherMeeting))
+}
+
+// ScheduleReport is the result of checking a set of sections as a weekly
+// schedule.
+type ScheduleReport struct {
+	Sections    []Section
+	Conflicts   []Conflict
+	CreditHours float64
+}
+
+// Overlaps reports whether any two meetings of the schedule overlap.
+func (r ScheduleReport) Overlaps() bool {
+	for _, conflict := range r.Conflicts {
+		if conflict.Kind == OverlapConflict {
+			return true
+		}
+	}
+	return false
+}
+
+// breakBetween returns the time between the end of one meeting and the start
+// of the other on a day both meet, and false when they never meet on the
+// same day or overlap.
+func breakBetween(a Meeting, b Meeting) (time.Duration, bool) {
+	if !a.Scheduled() || !b.Scheduled() || a.Overlaps(b) {
+		return 0, false
+	}
+	if a.EndDate.Before(b.StartDate) || b.EndDate.Before(a.StartDate) {
+		return 0, false
+	}
+	shared := false
+	for _, day := range a.Days {
+		for _, otherDay := range b.Days {
+			if day == otherDay {
+				shared = true
+			}
+		}
+	}
+	if !shared {
+		return 0, false
+	}
+	gap := b.Begin.Minutes - a.End.Minutes
+	if gap < 0 {
+		gap = a.Begin.Minutes - b.End.Minutes
+	}
+	return time.Duration(gap) * time.Minute, true
+}
+
+// meetingCampus returns the campus code of a meeting, or of its section when
+// the meeting has none.
+func meetingCampus(section Section, meeting Meeting) string {
+	if len(meeting.Campus) > 0 {
+		return meeting.Campus
+	}
+	return section.Campus
+}
+
+// sectionCreditHours returns the units of a section, falling back to its
+// meetings when the section itself does not say.
+func sectionCreditHours(section Section) float64 {
+	if section.CreditHours > 0 {
+		return section.CreditHours
+	}
+	var hours float64
+	for _, meeting := range section.Meetings {
+		if meeting.CreditHours > hours {
+			hours = meeting.CreditHours
+		}
+	}
+	return hours
+}
+
+// CheckSchedule compares the meetings of every pair of sections, honoring
+// the start and end dates of part of term sections, and totals their units.
+func CheckSchedule(sections []Section) ScheduleReport {
+	report := ScheduleReport{Sections: sections}
+	for i, section := range sections {
+		report.CreditHours += sectionCreditHours(section)
+		for _, other := range sections[i+1:] {
+			for _, meeting := range section.Meetings {
+				for _, otherMeeting := range other.Meetings {
+					conflict := Conflict{Section: section, Meeting: meeting, Other: other, OtherMeeting: otherMeeting}
+					if meeting.Overlaps(otherMeeting) {
+						conflict.Kind = OverlapConflict
+						report.Conflicts = append(report.Conflicts, conflict)
+						continue
+					}
+					gap, ok := breakBetween(meeting, otherMeeting)
+					campus, otherCampus := meetingCampus(section, meeting), meetingCampus(other, otherMeeting)
+					if ok && gap < MinTravelTime && len(campus) > 0 && len(otherCampus) > 0 && campus != otherCampus {
+						conflict.Kind = TravelConflict
+						report.Conflicts = append(report.Conflicts, conflict)
+					}
+				}
+			}
+		}
+	}
+	return report
+}
+
+// LoadMeetings replaces the meetings and instructors of section with the
+// ones Banner has for its CRN.
+func (task *Task) LoadMeetings(section *Section) error {
+	meetingTimes, err := task.GetMeetingTimes(section.Term, section.CRN)
+	if err != nil {
+		return err
+	}
+	seen := map[string]bool{}
+	section.Meetings = nil
+	section.Instructors = nil
+	for _, meetingFaculty := range meetingTimes {
+		meeting := newMeeting(meetingFaculty)
+		section.Meetings = append(section.Meetings, meeting)
+		for _, instructor := range meeting.Instructors {
+			if !seen[instructor.BannerID] {
+				seen[instructor.BannerID] = true
+				section.Instructors = append(section.Instructors, instructor)
+			}
+		}
+	}
+	return nil
+}
+
+// CheckSchedule checks the sections of the current round, with the linked
+// sections resolved for them, against each other and against the sections
+// registered in earlier rounds, before anything is added. Overlapping classes
+// return ScheduleConflict unless AllowConflicts is set or it is a dry run;
+// tight changes of campus are only reported. Sections whose meeting times
+// cannot be loaded are left out of the check.
+func (s *SignupTask) CheckSchedule() error {
+	fmt.Println("Checking schedule")
+
+	var crns []string
+	for _, section := range s.Registered {
+		crns = append(crns, section.CRN)
+	}
+	for _, group := range s.Groups {
+		crns = append(crns, group...)
+	}
+
+	var sections []Section
+	for _, crn := range crns {
+		section := Section{Term: s.task.TermId, CRN: crn}
+		err := s.task.LoadMeetings(&section)
+		if err == SessionExpired {
+			return err
+		}
+		if err != nil {
+			fmt.Printf("Warning: could not get meeting times of %s: %s\n", section.CRN, err)
+			continue
+		}
+		sections = append(sections, section)
+	}
+
+	report := CheckSchedule(sections)
+	s.Schedule = &report
+	fmt.Printf("%d sections, %g units\n", len(report.Sections), report.CreditHours)
+	for _, conflict := range report.Conflicts {
+		fmt.Printf("Warning: %s\n", conflict)
+	}
+	if report.Overlaps() && !s.task.AllowConflicts && !s.task.DryRun {
+		return ScheduleConflict
+	}
+	return nil
+}
+
+// skipConflicts takes the groups of the current round that overlap a
+// registered section, or a group earlier in the round, out of the round,
+// records the conflict as their result and marks them tried, so the next
+// alternate is tried instead.
+func (s *SignupTask) skipConflicts(tried map[string]bool) {
+	sections := map[string]Section{}
+	for _, section := range s.Schedule.Sections {
+		sections[section.CRN] = section
+	}
+	var kept []Section
+	for _, section := range s.Registered {
+		kept = append(kept, sections[section.CRN])
+	}
+
+	var groups [][]string
+	for _, group := range s.Groups {
+		var conflict string
+		for _, crn := range group {
+			for _, other := range kept {
+				if len(conflict) == 0 && sections[crn].Conflicts(other) {
+					conflict = fmt.Sprintf("%s overlaps %s", crn, other.CRN)
+				}
+			}
+		}
+		if len(conflict) == 0 {
+			for _, crn := range group {
+				kept = append(kept, sections[crn])
+			}
+			groups = append(groups, group)
+			continue
+		}
+
+		fmt.Printf("Skipping %s: %s\n", group[0], conflict)
+		s.recordResult(RegistrationResult{CRN: group[0], Status: ScheduleConflictStatus, Errors: []string{conflict}})
+		tried[group[0]] = true
+		for i, crn := range s.round {
+			if crn == group[0] {
+				s.round = append(s.round[:i], s.round[i+1:]...)
+				break
+			}
+		}
+	}
+	s.Groups = groups
+}
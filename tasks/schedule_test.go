@@ -0,0 +1,137 @@
+package tasks
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckSchedule(t *testing.T) {
+	startDate, _ := time.Parse(BannerDateLayout, "01/08/2024")
+	midTerm, _ := time.Parse(BannerDateLayout, "02/16/2024")
+	endDate, _ := time.Parse(BannerDateLayout, "03/29/2024")
+	section := func(crn string, campus string, days string, begin string, end string, from time.Time, to time.Time) Section {
+		meeting := Meeting{Begin: ParseBannerTime(begin), End: ParseBannerTime(end), StartDate: from, EndDate: to, Campus: campus}
+		for _, letter := range days {
+			for day, dayLetter := range weekdayLetters {
+				if string(letter) == dayLetter {
+					meeting.Days = append(meeting.Days, day)
+				}
+			}
+		}
+		return Section{CRN: crn, CreditHours: 4, Meetings: []Meeting{meeting}}
+	}
+
+	report := CheckSchedule([]Section{
+		section("1", "DA", "MW", "0900", "1020", startDate, endDate),
+		// First half of the term only, after 1 ends: no conflict.
+		section("2", "DA", "MW", "1030", "1150", startDate, midTerm),
+		// Second half of the term, overlapping 1.
+		section("3", "DA", "W", "1000", "1100", midTerm.AddDate(0, 0, 1), endDate),
+		// Ten minutes after 1 ends, on another campus.
+		section("4", "FH", "M", "1030", "1120", midTerm.AddDate(0, 0, 1), endDate),
+	})
+
+	if report.CreditHours != 16 {
+		t.Errorf("credit hours = %g, want 16", report.CreditHours)
+	}
+	var overlaps, travel []string
+	for _, conflict := range report.Conflicts {
+		pair := conflict.Section.CRN + "-" + conflict.Other.CRN
+		if conflict.Kind == OverlapConflict {
+			overlaps = append(overlaps, pair)
+		} else {
+			travel = append(travel, pair)
+		}
+	}
+	if len(overlaps) != 1 || overlaps[0] != "1-3" {
+		t.Errorf("overlaps = %v, want [1-3]", overlaps)
+	}
+	if len(travel) != 1 || travel[0] != "1-4" {
+		t.Errorf("travel conflicts = %v, want [1-4]", travel)
+	}
+	if !report.Overlaps() {
+		t.Error("report should have overlaps")
+	}
+}
+
+func TestCheckScheduleComparesCampusCodes(t *testing.T) {
+	startDate, _ := time.Parse(BannerDateLayout, "01/08/2024")
+	endDate, _ := time.Parse(BannerDateLayout, "03/29/2024")
+	meeting := func(campus string, begin string, end string) Meeting {
+		return Meeting{Days: Weekdays{time.Monday}, Begin: ParseBannerTime(begin), End: ParseBannerTime(end), StartDate: startDate, EndDate: endDate, Campus: campus}
+	}
+	// The first meeting has no campus of its own, so the section's is used.
+	report := CheckSchedule([]Section{
+		{CRN: "1", Campus: "DA", CampusDescription: "De Anza", Meetings: []Meeting{meeting("", "0900", "1020")}},
+		{CRN: "2", Campus: "DA", CampusDescription: "De Anza", Meetings: []Meeting{meeting("DA", "1030", "1120")}},
+	})
+	if len(report.Conflicts) != 0 {
+		t.Errorf("conflicts = %v", report.Conflicts)
+	}
+}
+
+func TestSignupTaskRefusesOverlappingPlan(t *testing.T) {
+	server := newMockServer(t)
+	addLinkedSections(server)
+	task := newMockTask(t, server)
+	task.CoursesToAdd = []string{"30001", "40003"}
+
+	signup := NewSignupTask(task)
+	if err := signup.Run(); err != ScheduleConflict {
+		t.Fatalf("err = %v, want %v", err, ScheduleConflict)
+	}
+	if server.Registered("30001") || server.Pending("30001") {
+		t.Error("30001 was added despite the conflict")
+	}
+	if signup.Schedule == nil || !signup.Schedule.Overlaps() {
+		t.Errorf("schedule = %+v", signup.Schedule)
+	}
+}
+
+func TestSignupTaskSkipsOverlappingAlternate(t *testing.T) {
+	server := newMockServer(t)
+	addLinkedSections(server)
+	task := newMockTask(t, server)
+	// 30002 is full, and the alternate 30001 overlaps the CHEM lecture
+	// registered in the first round, so 30003 is tried next.
+	task.CourseChoices = [][]string{{"30002", "30001", "30003"}, {"40001"}}
+
+	signup := NewSignupTask(task)
+	if err := signup.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if !server.Registered("40001") || !server.Registered("40004") || !server.Registered("30003") {
+		t.Error("did not register 40001, 40004 and 30003")
+	}
+	if server.Registered("30001") || server.Pending("30001") {
+		t.Error("30001 was added despite the conflict")
+	}
+	if result, _ := signup.result("30001"); result.Status != ScheduleConflictStatus {
+		t.Errorf("30001 result = %+v", result)
+	}
+	if signup.Chosen[0] != "30003" {
+		t.Errorf("chosen = %v", signup.Chosen)
+	}
+}
+
+func TestSignupTaskChecksLinkedCompanions(t *testing.T) {
+	server := newMockServer(t)
+	addLinkedSections(server)
+	// With 40004 full and 40003 clashing with the lecture, 40002 is picked as
+	// the lab of 40001, and it overlaps 30002.
+	for i := range server.Sections {
+		if server.Sections[i].CRN == "40004" {
+			server.Sections[i].Enrolled = server.Sections[i].Capacity
+		}
+	}
+	task := newMockTask(t, server)
+	task.CoursesToAdd = []string{"30002", "40001"}
+
+	signup := NewSignupTask(task)
+	if err := signup.Run(); err != ScheduleConflict {
+		t.Fatalf("err = %v, want %v", err, ScheduleConflict)
+	}
+	if server.Pending("40001") || server.Pending("40002") {
+		t.Error("linked sections were added despite the conflict")
+	}
+}
@@ -0,0 +1,199 @@
+package tasks
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"time"
+
+	http "github.com/bogdanfinn/fhttp"
+)
+
+const (
+	DefaultClockSamples = 5
+	// SessionRefreshLead is how long before registration opens the session is
+	// refreshed and the clock offset measured again.
+	SessionRefreshLead = time.Minute
+	// DefaultRegistrationPoll is how often the registration status is checked
+	// again when registration has not opened at the expected moment.
+	DefaultRegistrationPoll = 250 * time.Millisecond
+	// MaxRegistrationPolling bounds how long the status is polled for before
+	// continuing anyway.
+	MaxRegistrationPolling = time.Minute
+	// spinBefore is how long before the deadline sleeping stops and the
+	// scheduler busy waits, since timers can fire late.
+	spinBefore = 20 * time.Millisecond
+)
+
+// clockSample is one request: the local times it was sent and received, and
+// the server's Date header, which has a one second resolution.
+type clockSample struct {
+	sent     time.Time
+	received time.Time
+	server   time.Time
+}
+
+// estimateOffset returns how far the server clock is ahead of the local one.
+// Each sample bounds the offset to [server-received, server+1s-sent]; the
+// estimate is the middle of the intersection of those bounds, or the median
+// of the sample midpoints when network jitter leaves no intersection.
+func estimateOffset(samples []clockSample) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+	low := samples[0].server.Sub(samples[0].received)
+	high := samples[0].server.Add(time.Second).Sub(samples[0].sent)
+	var midpoints []time.Duration
+	for _, sample := range samples {
+		sampleLow := sample.server.Sub(sample.received)
+		sampleHigh := sample.server.Add(time.Second).Sub(sample.sent)
+		if sampleLow > low {
+			low = sampleLow
+		}
+		if sampleHigh < high {
+			high = sampleHigh
+		}
+		midpoints = append(midpoints, (sampleLow+sampleHigh)/2)
+	}
+	if low <= high {
+		return (low + high) / 2
+	}
+	sort.Slice(midpoints, func(i, j int) bool { return midpoints[i] < midpoints[j] })
+	return midpoints[len(midpoints)/2]
+}
+
+func (task *Task) sampleClock() (clockSample, error) {
+	request, err := http.NewRequest(http.MethodGet, task.Institution.Registration("/ssb/classSearch/getTerms?searchTerm=&offset=1&max=1"), nil)
+	if err != nil {
+		return clockSample{}, FailedToCreateRequest
+	}
+	request.Header.Add("accept", "application/json")
+	request.Header.Add("accept-language", "en-US,en;q=0.9")
+	request.Header.Add("user-agent", task.UserAgent)
+
+	sent := time.Now()
+	resp, err := task.Client.Do(request)
+	if err != nil {
+		return clockSample{}, FailedToMakeRequest
+	}
+	received := time.Now()
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	server, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return clockSample{}, FailedReadingServerClock
+	}
+	return clockSample{sent: sent, received: received, server: server}, nil
+}
+
+// EstimateClockOffset measures how far the registration server's clock is
+// ahead of the local clock from the Date headers of a few requests. The
+// samples are spread over a second so the Date header's rounding averages
+// out.
+func (task *Task) EstimateClockOffset(samples int) (time.Duration, error) {
+	if samples <= 0 {
+		samples = DefaultClockSamples
+	}
+	var collected []clockSample
+	for i := 0; i < samples; i++ {
+		sample, err := task.sampleClock()
+		if err != nil {
+			return 0, err
+		}
+		collected = append(collected, sample)
+		if i < samples-1 {
+			time.Sleep(time.Second / time.Duration(samples))
+		}
+	}
+	offset := estimateOffset(collected)
+	fmt.Printf("Server clock offset: %s\n", offset.Round(time.Millisecond))
+	return offset, nil
+}
+
+// sleepUntil sleeps until the local deadline, busy waiting through the last
+// few milliseconds so it wakes up on time.
+func sleepUntil(deadline time.Time) {
+	if wait := time.Until(deadline) - spinBefore; wait > 0 {
+		time.Sleep(wait)
+	}
+	for time.Now().Before(deadline) {
+		runtime.Gosched()
+	}
+}
+
+// WaitForRegistration waits until registration opens at opensAt on the
+// server's clock, less RegistrationLead. The session is kept alive while
+// waiting and refreshed shortly before, and the status is polled from then
+// on until Banner reports registration open.
+func (s *SignupTask) WaitForRegistration(opensAt time.Time) error {
+	offset, err := s.task.EstimateClockOffset(DefaultClockSamples)
+	if err != nil {
+		fmt.Println(err)
+	}
+	if !time.Now().Add(offset).Before(opensAt) {
+		fmt.Println("Past registration time")
+		return nil
+	}
+
+	keepAlive := s.task.KeepAlive
+	if keepAlive <= 0 {
+		keepAlive = DefaultKeepAlive
+	}
+	fireAt := opensAt.Add(-s.task.RegistrationLead)
+	refreshAt := fireAt.Add(-SessionRefreshLead)
+
+	resumeDate := fireAt.Add(-offset)
+	fmt.Printf("Will continue after: %s\n", resumeDate.Format("2006-01-02 03:04:05.000 -0700 MST"))
+	fmt.Printf("Waiting %s or %s to continue\n", formatDuration(time.Until(resumeDate)), time.Until(resumeDate).Round(time.Millisecond))
+
+	for {
+		untilRefresh := time.Until(refreshAt.Add(-offset))
+		if untilRefresh <= 0 {
+			break
+		}
+		if untilRefresh > keepAlive {
+			time.Sleep(keepAlive)
+			if err := s.VisitClassRegistration(); err != nil {
+				return err
+			}
+			continue
+		}
+		time.Sleep(untilRefresh)
+	}
+
+	if time.Until(fireAt.Add(-offset)) > 0 {
+		fmt.Println("Refreshing session before registration opens")
+		if err := s.VisitClassRegistration(); err != nil {
+			return err
+		}
+		if refreshed, err := s.task.EstimateClockOffset(DefaultClockSamples); err == nil {
+			offset = refreshed
+		}
+	}
+
+	sleepUntil(fireAt.Add(-offset))
+	fmt.Printf("Registration opening, local time %s\n", time.Now().Format("15:04:05.000"))
+
+	poll := s.task.RegistrationPoll
+	if poll <= 0 {
+		poll = DefaultRegistrationPoll
+	}
+	deadline := time.Now().Add(MaxRegistrationPolling + s.task.RegistrationLead)
+	for {
+		stillClosed, err := s.registrationOpensAt()
+		if err != nil {
+			return err
+		}
+		if stillClosed.IsZero() {
+			fmt.Println("Registration is open")
+			return nil
+		}
+		if time.Now().After(deadline) {
+			fmt.Println("Registration still reported closed, continuing anyway")
+			return nil
+		}
+		time.Sleep(poll)
+	}
+}
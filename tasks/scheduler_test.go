@@ -0,0 +1,60 @@
+package tasks
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEstimateOffset(t *testing.T) {
+	offset := 2300 * time.Millisecond
+	base := time.Date(2024, 11, 1, 7, 59, 0, 0, time.UTC)
+	var samples []clockSample
+	for i := 0; i < 10; i++ {
+		sent := base.Add(time.Duration(i) * 97 * time.Millisecond)
+		received := sent.Add(10 * time.Millisecond)
+		server := sent.Add(5 * time.Millisecond).Add(offset).Truncate(time.Second)
+		samples = append(samples, clockSample{sent: sent, received: received, server: server})
+	}
+	if got := estimateOffset(samples); got < offset-100*time.Millisecond || got > offset+100*time.Millisecond {
+		t.Errorf("offset = %s, want about %s", got, offset)
+	}
+}
+
+func TestEstimateClockOffsetFromServer(t *testing.T) {
+	server := newMockServer(t)
+	server.ClockSkew = -3 * time.Second
+	task := newMockTask(t, server)
+
+	offset, err := task.EstimateClockOffset(DefaultClockSamples)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset < server.ClockSkew-300*time.Millisecond || offset > server.ClockSkew+300*time.Millisecond {
+		t.Errorf("offset = %s, want about %s", offset, server.ClockSkew)
+	}
+}
+
+func TestSignupTaskWaitsForRegistration(t *testing.T) {
+	server := newMockServer(t)
+	server.ClockSkew = 4 * time.Second
+	server.RegistrationOpensAt = time.Now().Add(server.ClockSkew + 3*time.Second)
+	task := newMockTask(t, server)
+	task.RegistrationPoll = 10 * time.Millisecond
+
+	signup := NewSignupTask(task)
+	var opened time.Time
+	wait := func() error {
+		if err := signup.WaitForRegistration(server.RegistrationOpensAt); err != nil {
+			return err
+		}
+		opened = time.Now().Add(server.ClockSkew)
+		return nil
+	}
+	err := task.RunAuthenticated(task.Institution.SSO.Registration, nil, []func() error{signup.SaveTerm, wait})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if late := opened.Sub(server.RegistrationOpensAt); late < 0 || late > 500*time.Millisecond {
+		t.Errorf("continued %s after registration opened", late)
+	}
+}
@@ -2,30 +2,67 @@ package tasks
 
 import (
 	"bytes"
+	"crypto/rand"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/url"
-	"os"
 	"strconv"
+	"sync"
 	"time"
 
 	http "github.com/bogdanfinn/fhttp"
 )
 
+const DefaultPageSize = 100
+
 type SearchTask struct {
-	task       *Task
-	courseInfo []CourseInfo
+	task     *Task
+	sections []Section
+}
+
+func (s *SearchTask) Criteria() SearchCriteria {
+	criteria := s.task.Criteria
+	if len(criteria.Subject) == 0 {
+		criteria.Subject = s.task.Subject
+	}
+	return criteria
+}
+
+type SearchQuery struct {
+	TermId          string
+	Subject         string
+	UniqueSessionId string
+}
+
+func (s *SearchTask) Queries() []SearchQuery {
+	termIds := s.task.TermIds
+	if len(termIds) == 0 {
+		termIds = []string{s.task.TermId}
+	}
+	subjects := s.task.Subjects
+	if len(subjects) == 0 {
+		subjects = []string{s.Criteria().Subject}
+	}
+
+	var queries []SearchQuery
+	for _, termId := range termIds {
+		for _, subject := range subjects {
+			queries = append(queries, SearchQuery{TermId: termId, Subject: subject})
+		}
+	}
+	return queries
 }
 
-func (s *SearchTask) SearchForTerm() error {
-	fmt.Println("Searching for term")
+func (s *SearchTask) SearchForTerm(query SearchQuery) error {
+	fmt.Printf("Searching for term %s\n", query.TermId)
 
 	data := url.Values{}
-	data.Set("term", s.task.TermId)
+	data.Set("term", query.TermId)
+	data.Set("uniqueSessionId", query.UniqueSessionId)
 
-	request, err := http.NewRequest(http.MethodPost, "https://reg-prod.ec.fhda.edu/StudentRegistrationSsb/ssb/term/search?mode=search", bytes.NewBufferString(data.Encode()))
+	request, err := http.NewRequest(http.MethodPost, s.task.Institution.Registration("/ssb/term/search?mode=search"), bytes.NewBufferString(data.Encode()))
 	if err != nil {
 		return FailedToCreateRequest
 	}
@@ -43,27 +80,44 @@ func (s *SearchTask) SearchForTerm() error {
 	if resp.StatusCode != 200 {
 		return UnknownHTTPResponseStatus
 	}
+	return nil
+}
 
-	body, err := io.ReadAll(resp.Body)
+func (s *SearchTask) ResetSearch(query SearchQuery) error {
+	data := url.Values{}
+	data.Set("uniqueSessionId", query.UniqueSessionId)
+
+	request, err := http.NewRequest(http.MethodPost, s.task.Institution.Registration("/ssb/classSearch/resetDataForm"), bytes.NewBufferString(data.Encode()))
 	if err != nil {
-		return FailedToReadResponseBody
+		return FailedToCreateRequest
 	}
 
-	fmt.Println(string(body))
+	request.Header.Set("accept", "*/*")
+	request.Header.Set("content-type", "application/x-www-form-urlencoded")
+	request.Header.Set("user-agent", s.task.UserAgent)
+
+	resp, err := s.task.Client.Do(request)
+	if err != nil {
+		return FailedToMakeRequest
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return FailedResettingSearch
+	}
 	return nil
 }
 
-func (s *SearchTask) GetCourses() error {
-	fmt.Println("Getting courses")
-
-	url := fmt.Sprintf(
-		"https://reg-prod.ec.fhda.edu/StudentRegistrationSsb/ssb/searchResults/searchResults?txt_subject=%s&txt_term=%s&startDatepicker=&endDatepicker=&pageOffset=0&pageMaxSize=100&sortColumn=subjectDescription&sortDirection=asc",
-		s.task.Subject, s.task.TermId,
-	)
+func (s *SearchTask) GetCoursesPage(query SearchQuery, offset int, size int) (Courses, error) {
+	criteria := s.Criteria()
+	criteria.Subject = query.Subject
+	values := criteria.Query(query.TermId, offset, size)
+	values.Set("uniqueSessionId", query.UniqueSessionId)
+	url := s.task.Institution.Registration("/ssb/searchResults/searchResults?" + values.Encode())
 
 	request, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
-		return FailedToCreateRequest
+		return Courses{}, FailedToCreateRequest
 	}
 
 	request.Header.Set("accept", "*/*")
@@ -71,123 +125,274 @@ func (s *SearchTask) GetCourses() error {
 
 	resp, err := s.task.Client.Do(request)
 	if err != nil {
-		return FailedToMakeRequest
+		return Courses{}, FailedToMakeRequest
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		return UnknownHTTPResponseStatus
+		return Courses{}, UnknownHTTPResponseStatus
 	}
 
 	readBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return FailedToReadResponseBody
+		return Courses{}, FailedToReadResponseBody
 	}
 
 	coursesResponse := Courses{}
 	if err := json.Unmarshal(readBytes, &coursesResponse); err != nil {
 		fmt.Println(err)
-		return UnableToParseJSON
+		return Courses{}, UnableToParseJSON
 	}
 
 	if !coursesResponse.Success {
-		return CourseSearchUnsuccessful
+		return Courses{}, CourseSearchUnsuccessful
+	}
+	return coursesResponse, nil
+}
+
+func (s *SearchTask) Search(query SearchQuery) ([]CourseSection, error) {
+	pageSize := s.task.PageSize
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	concurrency := s.task.PageConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
 	}
 
-	fmt.Printf("Total count of courses: %d\n", coursesResponse.TotalCount)
+	if err := s.SearchForTerm(query); err != nil {
+		return nil, err
+	}
+	defer s.ResetSearch(query)
 
-	if coursesResponse.TotalCount == 0 {
+	coursesResponse, err := s.GetCoursesPage(query, 0, pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("Total count of %s courses in %s: %d\n", query.Subject, query.TermId, coursesResponse.TotalCount)
+
+	// Banner caps pageMaxSize, so later pages are as large as the first one
+	// came back rather than as large as asked for.
+	if len(coursesResponse.Data) > 0 && len(coursesResponse.Data) < pageSize {
+		pageSize = len(coursesResponse.Data)
+	}
+	var offsets []int
+	for offset := len(coursesResponse.Data); offset < coursesResponse.TotalCount; offset += pageSize {
+		offsets = append(offsets, offset)
+	}
+	if len(coursesResponse.Data) > 0 && len(offsets) > 0 {
+		fmt.Printf("Fetching %d more pages of %d\n", len(offsets), pageSize)
+
+		pages := make([]Courses, len(offsets))
+		errs := make([]error, len(offsets))
+		semaphore := make(chan struct{}, concurrency)
+		var wg sync.WaitGroup
+		for i, offset := range offsets {
+			wg.Add(1)
+			go func(i int, offset int) {
+				defer wg.Done()
+				semaphore <- struct{}{}
+				defer func() { <-semaphore }()
+				pages[i], errs[i] = s.GetCoursesPage(query, offset, pageSize)
+			}(i, offset)
+		}
+		wg.Wait()
+
+		for i, page := range pages {
+			if errs[i] != nil {
+				return nil, errs[i]
+			}
+			coursesResponse.Data = append(coursesResponse.Data, page.Data...)
+		}
+	}
+	coursesResponse.SectionsFetchedCount = len(coursesResponse.Data)
+
+	if coursesResponse.SectionsFetchedCount < coursesResponse.TotalCount {
+		fmt.Printf("Fetched %d of %d sections\n", coursesResponse.SectionsFetchedCount, coursesResponse.TotalCount)
+		return nil, IncompleteSearch
+	}
+	return coursesResponse.Data, nil
+}
+
+func (s *SearchTask) GetCourses() error {
+	criteria := s.Criteria()
+	fmt.Printf("Getting courses for %s\n", criteria)
+
+	// Establish the JSESSIONID before the workers share it.
+	if err := s.ResetSearch(SearchQuery{}); err != nil {
+		return err
+	}
+
+	queries := s.Queries()
+	concurrency := s.task.SearchConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	results := make([][]CourseSection, len(queries))
+	errs := make([]error, len(queries))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for worker := 0; worker < concurrency && worker < len(queries); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			uniqueSessionId := NewUniqueSessionId()
+			for i := range jobs {
+				query := queries[i]
+				query.UniqueSessionId = uniqueSessionId
+				results[i], errs[i] = s.Search(query)
+			}
+		}()
+	}
+	for i := range queries {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	seen := map[string]bool{}
+	var sections []Section
+	for i, result := range results {
+		if errs[i] != nil {
+			return errs[i]
+		}
+		for _, raw := range result {
+			section := NewSection(raw)
+			if seen[section.Key()] {
+				continue
+			}
+			seen[section.Key()] = true
+			sections = append(sections, section)
+		}
+	}
+
+	if len(sections) == 0 {
 		fmt.Println("No courses found")
 		return CourseSearchUnsuccessful
 	}
+	fmt.Printf("Found %d unique sections across %d searches\n", len(sections), len(queries))
 
-	var courses []CourseInfo
-	for _, section := range coursesResponse.Data {
-		for _, faculty := range section.Faculty {
-			for _, meetingfaculty := range section.MeetingsFaculty {
-				course := CourseInfo{
-					TermDesc:              section.TermDesc,
-					CourseReferenceNumber: faculty.CourseReferenceNumber,
-					Subject:               section.Subject,
-					CourseNumber:          section.CourseNumber,
-					SequenceNumber:        section.SequenceNumber,
-					CourseTitle:           section.CourseTitle,
-					DisplayName:           faculty.DisplayName,
-					BeginTime:             Convert24HourTimeTo12HourFormat(meetingfaculty.MeetingTime.BeginTime),
-					EndTime:               Convert24HourTimeTo12HourFormat(meetingfaculty.MeetingTime.EndTime),
-					StartDate:             meetingfaculty.MeetingTime.StartDate,
-					EndDate:               meetingfaculty.MeetingTime.EndDate,
-					MeetingType:           meetingfaculty.MeetingTime.MeetingTypeDescription,
-					Room:                  meetingfaculty.MeetingTime.Room,
-					MaximumEnrollment:     section.MaximumEnrollment,
-					Enrollment:            section.Enrollment,
-					SeatsAvailable:        section.SeatsAvailable,
-					WaitAvailable:         section.WaitAvailable,
-				}
-				courses = append(courses, course)
-			}
+	var matched []Section
+	for _, section := range sections {
+		if criteria.Matches(section) {
+			matched = append(matched, section)
 		}
 	}
-	s.courseInfo = courses
+	if len(matched) < len(sections) {
+		fmt.Printf("Filtered to %d sections\n", len(matched))
+	}
+
+	s.sections = matched
 	return nil
 }
 
-func (s *SearchTask) ExportSearchData() error {
+func (s *SearchTask) Sections() []Section {
+	return s.sections
+}
+
+func (s *SearchTask) PersistSnapshots() error {
+	fmt.Printf("Recording %d section snapshots\n", len(s.sections))
+	return s.task.Snapshots.Record(time.Now(), s.sections)
+}
 
+func (s *SearchTask) ExportSearchData() error {
 	fmt.Println("Exporting search data")
 
-	currentTime := time.Now()
-	fileName := fmt.Sprintf("%s.csv", currentTime.Format("2006-01-02_15-04-05"))
-	file, err := os.Create(fileName)
+	format := s.task.exportFormat()
+	output, closeOutput, err := s.task.createOutput("", string(format))
 	if err != nil {
-		fmt.Println(err)
+		return err
+	}
+	defer closeOutput()
+
+	switch format {
+	case FormatJSON:
+		err = writeJSONExport(output, s.sections)
+	case FormatNDJSON:
+		err = writeNDJSONExport(output, s.sections)
+	case FormatICS:
+		err = WriteICS(output, s.sections, s.task.Institution.Location())
+	case FormatANSI, FormatHTML, FormatSVG:
+		err = writeWeekViews(output, format, []WeekView{{Title: s.Criteria().String(), Sections: s.sections}})
+	default:
+		err = s.writeCSV(output)
+	}
+	if err != nil {
+		return err
 	}
-	defer file.Close()
+	fmt.Println("Exported search data")
+	return nil
+}
 
-	writer := csv.NewWriter(file)
+func (s *SearchTask) writeCSV(output io.Writer) error {
+	writer := csv.NewWriter(output)
 	defer writer.Flush()
 
-	header := []string{"Term", "Course Reference Number", "Subject", "Course Number", "Sequence Number", "Course Title", "Display Name", "Begin Time", "End Time", "Start Date", "End Date", "Meeting Type", "Room", "Maximum Enrollment", "Enrollment", "Seats Available", "Waitlist Available"}
-	fmt.Printf("Writing to %s\n", fileName)
-	err = writer.Write(header)
+	header := []string{
+		"Term", "Course Reference Number", "Subject", "Course Number", "Sequence Number", "Course Title", "Display Name",
+		"Begin Time", "End Time", "Start Date", "End Date", "Meeting Type", "Room", "Maximum Enrollment", "Enrollment",
+		"Seats Available", "Waitlist Available", "Days", "Building", "Campus", "Credit Hours", "Waitlist Capacity",
+		"Waitlist Count", "Instructional Method", "Attributes", "Link Identifier", "Cross List", "Instructor Email",
+	}
+	err := writer.Write(header)
 	if err != nil {
 		return FailedToWrite
 	}
-	for _, course := range s.courseInfo {
-		record := []string{
-			course.TermDesc,
-			course.CourseReferenceNumber,
-			course.Subject,
-			course.CourseNumber,
-			course.SequenceNumber,
-			course.CourseTitle,
-			course.DisplayName,
-			course.BeginTime,
-			course.EndTime,
-			course.StartDate,
-			course.EndDate,
-			course.MeetingType,
-			course.Room,
-			strconv.Itoa(course.MaximumEnrollment),
-			strconv.Itoa(course.Enrollment),
-			strconv.Itoa(course.SeatsAvailable),
-			strconv.Itoa(course.WaitAvailable),
+	for _, section := range s.sections {
+		meetings := section.Meetings
+		if len(meetings) == 0 {
+			meetings = []Meeting{{}}
 		}
-		err = writer.Write(record)
-		if err != nil {
-			return FailedToWrite
+		for _, meeting := range meetings {
+			record := []string{
+				section.TermDescription,
+				section.CRN,
+				section.Subject,
+				section.CourseNumber,
+				section.SequenceNumber,
+				section.Title,
+				section.InstructorNames(),
+				meeting.Begin.String(),
+				meeting.End.String(),
+				formatBannerDate(meeting.StartDate),
+				formatBannerDate(meeting.EndDate),
+				meeting.TypeDescription,
+				meeting.Room,
+				strconv.Itoa(section.MaximumEnrollment),
+				strconv.Itoa(section.Enrollment),
+				strconv.Itoa(section.SeatsAvailable),
+				strconv.Itoa(section.WaitAvailable),
+				meeting.DayLetters(),
+				meeting.Building,
+				section.CampusDescription,
+				strconv.FormatFloat(section.CreditHours, 'f', -1, 64),
+				strconv.Itoa(section.WaitCapacity),
+				strconv.Itoa(section.WaitCount),
+				section.InstructionalMethodDescription,
+				section.AttributeCodes(),
+				section.LinkIdentifier,
+				section.CrossList,
+				section.InstructorEmails(),
+			}
+			err = writer.Write(record)
+			if err != nil {
+				return FailedToWrite
+			}
 		}
 	}
-	fmt.Println("Exported search data")
 	return nil
 }
 
 func (s *SearchTask) Run() error {
 	steps := []func() error{
-		s.SearchForTerm,
 		s.GetCourses,
-		s.ExportSearchData,
 	}
+	if s.task.Snapshots != nil {
+		steps = append(steps, s.PersistSnapshots)
+	}
+	steps = append(steps, s.ExportSearchData)
 
 	for _, step := range steps {
 		if err := Retry(s.task.RetryAmount, s.task.RetryDuration, step); err != nil {
@@ -199,6 +404,18 @@ func (s *SearchTask) Run() error {
 	return nil
 }
 
+// NewUniqueSessionId mimics the id Banner's class search page generates to
+// keep concurrent searches within one session apart.
+func NewUniqueSessionId() string {
+	letters := "abcdefghijklmnopqrstuvwxyz0123456789"
+	random := make([]byte, 5)
+	rand.Read(random)
+	for i, b := range random {
+		random[i] = letters[int(b)%len(letters)]
+	}
+	return fmt.Sprintf("%s%d", random, time.Now().UnixMilli())
+}
+
 func NewSearchTask(task *Task) *SearchTask {
 	return &SearchTask{task: task}
 }
@@ -0,0 +1,236 @@
+package tasks
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/veil/mock"
+)
+
+func TestSearchTaskExportsSubject(t *testing.T) {
+	server := newMockServer(t)
+	task := newMockTask(t, server)
+	dir := chdirTemp(t)
+
+	termDesc, err := task.SearchTerm()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if termDesc != "2024 Winter De Anza" {
+		t.Errorf("term description = %q", termDesc)
+	}
+
+	search := NewSearchTask(task)
+	if err := search.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(search.sections) != 2 {
+		t.Fatalf("got %d rows, want 2", len(search.sections))
+	}
+	for _, course := range search.sections {
+		if course.Subject != "PHYS" {
+			t.Errorf("unexpected subject %s", course.Subject)
+		}
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.csv"))
+	if len(files) != 1 {
+		t.Fatalf("got %d csv files, want 1", len(files))
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) == 0 {
+		t.Error("empty export")
+	}
+}
+
+func TestSearchTaskUnknownTerm(t *testing.T) {
+	server := newMockServer(t)
+	task := newMockTask(t, server)
+	task.TermId = "209932"
+
+	if _, err := task.SearchTerm(); err != TermNotFound {
+		t.Errorf("err = %v, want %v", err, TermNotFound)
+	}
+}
+
+func TestSearchTaskFetchesEveryPage(t *testing.T) {
+	server := newMockServer(t)
+	for i := 0; i < 245; i++ {
+		server.Sections = append(server.Sections, mock.Section{
+			Term: "202432", CRN: fmt.Sprintf("4%04d", i), Subject: "MATH", CourseNumber: "10", Sequence: fmt.Sprintf("%02d", i),
+			Title: "STATISTICS", Capacity: 40, Instructor: "Bayes, Thomas", Days: "MW", Begin: "1030", End: "1220",
+		})
+	}
+	task := newMockTask(t, server)
+	task.Subject = "MATH"
+	// Banner returns fewer sections per page than asked for.
+	server.MaxPageSize = 40
+	task.PageSize = 50
+	task.PageConcurrency = 3
+
+	search := NewSearchTask(task)
+	if err := search.GetCourses(); err != nil {
+		t.Fatal(err)
+	}
+
+	seen := map[string]bool{}
+	for _, course := range search.sections {
+		seen[course.CRN] = true
+	}
+	if len(seen) != 246 {
+		t.Errorf("got %d sections, want 246", len(seen))
+	}
+}
+
+func TestSearchTaskAppliesCriteria(t *testing.T) {
+	server := newMockServer(t)
+	server.Sections = append(server.Sections,
+		mock.Section{Term: "202432", CRN: "30004", Subject: "PHYS", CourseNumber: "4B", Sequence: "01", Title: "PHYSICS FOR SCIENTISTS", CreditHours: 6, Capacity: 40, Instructor: "Newton, Isaac", Days: "MW", Begin: "1800", End: "1950", InstructionalMethod: "Online", Attributes: []string{"ZTC"}},
+		mock.Section{Term: "202432", CRN: "30005", Subject: "PHYS", CourseNumber: "4A", Sequence: "03", Title: "PHYSICS FOR SCIENTISTS", CreditHours: 6, Capacity: 40, Instructor: "Newton, Isaac", Days: "MWF", Begin: "0930", End: "1120", InstructionalMethod: "In Person"},
+	)
+	task := newMockTask(t, server)
+	task.Criteria = SearchCriteria{
+		CourseNumber: "4A",
+		OpenOnly:     true,
+		Instructor:   "newton",
+		Days:         "MW",
+		StartTime:    "0800",
+		EndTime:      "1200",
+	}
+
+	search := NewSearchTask(task)
+	if err := search.GetCourses(); err != nil {
+		t.Fatal(err)
+	}
+	if len(search.sections) != 1 || search.sections[0].CRN != "30001" {
+		t.Fatalf("got %+v, want only 30001", search.sections)
+	}
+
+	task.Criteria = SearchCriteria{Attributes: []string{"ZTC"}, InstructionalMethod: "Online"}
+	if err := search.GetCourses(); err != nil {
+		t.Fatal(err)
+	}
+	if len(search.sections) != 1 || search.sections[0].CRN != "30004" {
+		t.Fatalf("got %+v, want only 30004", search.sections)
+	}
+}
+
+func TestSearchCriteriaSendsOnlyCodes(t *testing.T) {
+	query := SearchCriteria{Campus: "DA", InstructionalMethod: "OL"}.Query("202432", 0, 10)
+	if query.Get("txt_campus") != "DA" || query.Get("txt_instructionalMethod") != "OL" {
+		t.Errorf("codes were not sent: %v", query)
+	}
+
+	criteria := SearchCriteria{Campus: "de anza", InstructionalMethod: "in person"}
+	query = criteria.Query("202432", 0, 10)
+	if query.Has("txt_campus") || query.Has("txt_instructionalMethod") {
+		t.Errorf("descriptions were sent: %v", query)
+	}
+	section := Section{Campus: "DA", CampusDescription: "De Anza", InstructionalMethod: "P", InstructionalMethodDescription: "In Person"}
+	if !criteria.Matches(section) {
+		t.Error("section did not match by description")
+	}
+	section.Campus, section.CampusDescription = "FH", "Foothill"
+	if criteria.Matches(section) {
+		t.Error("section on another campus matched")
+	}
+}
+
+func TestParseCreditHours(t *testing.T) {
+	min, max, err := ParseCreditHours("4-5")
+	if err != nil || min != 4 || max != 5 {
+		t.Errorf("got %v %v %v", min, max, err)
+	}
+	if _, _, err := ParseCreditHours("four"); err != InvalidCreditHours {
+		t.Errorf("err = %v", err)
+	}
+	if value, err := ParseTimeOfDay("9:30"); err != nil || value != "0930" {
+		t.Errorf("got %q %v", value, err)
+	}
+	if _, err := ParseTimeOfDay("2575"); err != InvalidTimeOfDay {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestSearchTaskCombinesSubjectsAndTerms(t *testing.T) {
+	server := newMockServer(t)
+	server.Terms = append(server.Terms, mock.Term{Code: "202442", Description: "2024 Spring De Anza"})
+	server.Sections = append(server.Sections,
+		mock.Section{Term: "202442", CRN: "30001", Subject: "PHYS", CourseNumber: "4B", Sequence: "01", Title: "PHYSICS FOR SCIENTISTS", Capacity: 40, Instructor: "Newton, Isaac", Days: "MW", Begin: "0930", End: "1120"},
+		mock.Section{Term: "202442", CRN: "40001", Subject: "CS", CourseNumber: "1A", Sequence: "01", Title: "JAVA", Capacity: 40, Instructor: "Hopper, Grace", Days: "TR", Begin: "0930", End: "1120"},
+	)
+	task := newMockTask(t, server)
+	task.Subjects = []string{"PHYS", "MATH", "CS", "PHYS"}
+	task.TermIds = []string{"202432", "202442"}
+	task.SearchConcurrency = 2
+
+	search := NewSearchTask(task)
+	if err := search.GetCourses(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]bool{}
+	for _, course := range search.sections {
+		key := course.TermDescription + "/" + course.CRN
+		if got[key] {
+			t.Errorf("duplicate section %s", key)
+		}
+		got[key] = true
+	}
+	for _, key := range []string{
+		"2024 Winter De Anza/30001",
+		"2024 Winter De Anza/30002",
+		"2024 Winter De Anza/30003",
+		"2024 Spring De Anza/30001",
+		"2024 Spring De Anza/40001",
+	} {
+		if !got[key] {
+			t.Errorf("missing section %s", key)
+		}
+	}
+	if len(got) != 5 {
+		t.Errorf("got %d sections, want 5", len(got))
+	}
+}
+
+func TestSearchTaskExportsNDJSON(t *testing.T) {
+	server := newMockServer(t)
+	task := newMockTask(t, server)
+	dir := chdirTemp(t)
+	task.OutputFormat = FormatNDJSON
+	task.Output = filepath.Join(dir, "sections.ndjson")
+
+	search := NewSearchTask(task)
+	if err := search.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(task.Output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var crns []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		section := Section{}
+		if err := json.Unmarshal(scanner.Bytes(), &section); err != nil {
+			t.Fatalf("%v: %s", err, scanner.Text())
+		}
+		if len(section.Meetings) != 1 || !section.Meetings[0].Begin.Valid {
+			t.Errorf("section %s lost its meeting", section.CRN)
+		}
+		crns = append(crns, section.CRN)
+	}
+	if len(crns) != 2 || crns[0] != "30001" || crns[1] != "30002" {
+		t.Errorf("got %v, want [30001 30002]", crns)
+	}
+}
@@ -0,0 +1,426 @@
+package tasks
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const BannerDateLayout = "01/02/2006"
+
+// Section is a class section as Banner describes it, without the flattening
+// done for the CSV export.
+type Section struct {
+	Term                           string       `json:"term"`
+	TermDescription                string       `json:"termDescription"`
+	CRN                            string       `json:"crn"`
+	Subject                        string       `json:"subject"`
+	SubjectDescription             string       `json:"subjectDescription"`
+	CourseNumber                   string       `json:"courseNumber"`
+	SequenceNumber                 string       `json:"sequenceNumber"`
+	Title                          string       `json:"title"`
+	PartOfTerm                     string       `json:"partOfTerm"`
+	Campus                         string       `json:"campus"`
+	CampusDescription              string       `json:"campusDescription"`
+	ScheduleType                   string       `json:"scheduleType"`
+	CreditHours                    float64      `json:"creditHours"`
+	CreditHoursHigh                float64      `json:"creditHoursHigh,omitempty"`
+	MaximumEnrollment              int          `json:"maximumEnrollment"`
+	Enrollment                     int          `json:"enrollment"`
+	SeatsAvailable                 int          `json:"seatsAvailable"`
+	WaitCapacity                   int          `json:"waitCapacity"`
+	WaitCount                      int          `json:"waitCount"`
+	WaitAvailable                  int          `json:"waitAvailable"`
+	Open                           bool         `json:"open"`
+	InstructionalMethod            string       `json:"instructionalMethod"`
+	InstructionalMethodDescription string       `json:"instructionalMethodDescription"`
+	Attributes                     []Attribute  `json:"attributes"`
+	LinkIdentifier                 string       `json:"linkIdentifier,omitempty"`
+	Linked                         bool         `json:"linked"`
+	CrossList                      string       `json:"crossList,omitempty"`
+	CrossListCapacity              int          `json:"crossListCapacity,omitempty"`
+	CrossListCount                 int          `json:"crossListCount,omitempty"`
+	CrossListAvailable             int          `json:"crossListAvailable,omitempty"`
+	Instructors                    []Instructor `json:"instructors"`
+	Meetings                       []Meeting    `json:"meetings"`
+}
+
+type Meeting struct {
+	Days                Weekdays     `json:"days"`
+	Begin               TimeOfDay    `json:"begin"`
+	End                 TimeOfDay    `json:"end"`
+	StartDate           time.Time    `json:"startDate"`
+	EndDate             time.Time    `json:"endDate"`
+	Building            string       `json:"building"`
+	BuildingDescription string       `json:"buildingDescription"`
+	Room                string       `json:"room"`
+	Campus              string       `json:"campus"`
+	CampusDescription   string       `json:"campusDescription"`
+	Type                string       `json:"type"`
+	TypeDescription     string       `json:"typeDescription"`
+	ScheduleType        string       `json:"scheduleType"`
+	HoursWeek           float64      `json:"hoursWeek"`
+	CreditHours         float64      `json:"creditHours"`
+	Instructors         []Instructor `json:"instructors"`
+}
+
+type Instructor struct {
+	BannerID string `json:"bannerId"`
+	Name     string `json:"name"`
+	Email    string `json:"email,omitempty"`
+	Primary  bool   `json:"primary"`
+}
+
+type Attribute struct {
+	Code        string `json:"code"`
+	Description string `json:"description"`
+	ZTC         bool   `json:"ztc"`
+}
+
+// TimeOfDay is a wall clock time in minutes after midnight. Meetings without
+// a scheduled time, such as asynchronous online classes, have Valid unset.
+type TimeOfDay struct {
+	Minutes int
+	Valid   bool
+}
+
+func ParseBannerTime(input string) TimeOfDay {
+	if len(input) != 4 {
+		return TimeOfDay{}
+	}
+	hour, err1 := strconv.Atoi(input[:2])
+	minute, err2 := strconv.Atoi(input[2:])
+	if err1 != nil || err2 != nil || hour > 23 || minute > 59 {
+		return TimeOfDay{}
+	}
+	return TimeOfDay{Minutes: hour*60 + minute, Valid: true}
+}
+
+func (t TimeOfDay) Hour() int {
+	return t.Minutes / 60
+}
+
+func (t TimeOfDay) Minute() int {
+	return t.Minutes % 60
+}
+
+// Banner returns the time in Banner's HHMM form.
+func (t TimeOfDay) Banner() string {
+	if !t.Valid {
+		return ""
+	}
+	return fmt.Sprintf("%02d%02d", t.Hour(), t.Minute())
+}
+
+func (t TimeOfDay) String() string {
+	return Convert24HourTimeTo12HourFormat(t.Banner())
+}
+
+func (t TimeOfDay) MarshalText() ([]byte, error) {
+	if !t.Valid {
+		return []byte{}, nil
+	}
+	return []byte(fmt.Sprintf("%02d:%02d", t.Hour(), t.Minute())), nil
+}
+
+func (t *TimeOfDay) UnmarshalText(text []byte) error {
+	*t = ParseBannerTime(strings.ReplaceAll(string(text), ":", ""))
+	return nil
+}
+
+func NewSection(raw CourseSection) Section {
+	section := Section{
+		Term:                           raw.Term,
+		TermDescription:                raw.TermDesc,
+		CRN:                            raw.CourseReferenceNumber,
+		Subject:                        raw.Subject,
+		SubjectDescription:             raw.SubjectDescription,
+		CourseNumber:                   raw.CourseNumber,
+		SequenceNumber:                 raw.SequenceNumber,
+		Title:                          raw.CourseTitle,
+		PartOfTerm:                     raw.PartOfTerm,
+		CampusDescription:              raw.CampusDescription,
+		ScheduleType:                   raw.ScheduleTypeDescription,
+		MaximumEnrollment:              raw.MaximumEnrollment,
+		Enrollment:                     raw.Enrollment,
+		SeatsAvailable:                 raw.SeatsAvailable,
+		WaitCapacity:                   raw.WaitCapacity,
+		WaitCount:                      raw.WaitCount,
+		WaitAvailable:                  raw.WaitAvailable,
+		Open:                           raw.OpenSection,
+		InstructionalMethod:            raw.InstructionalMethod,
+		InstructionalMethodDescription: raw.InstructionalMethodDescription,
+		LinkIdentifier:                 stringValue(raw.LinkIdentifier),
+		Linked:                         raw.IsSectionLinked,
+		CrossList:                      stringValue(raw.CrossList),
+		CrossListCapacity:              intValue(raw.CrossListCapacity),
+		CrossListCount:                 intValue(raw.CrossListCount),
+		CrossListAvailable:             intValue(raw.CrossListAvailable),
+	}
+
+	if credits, ok := creditHours(raw.CreditHours); ok {
+		section.CreditHours = credits
+	} else if credits, ok := creditHours(raw.CreditHourLow); ok {
+		section.CreditHours = credits
+	}
+	if credits, ok := creditHours(raw.CreditHourHigh); ok && credits > section.CreditHours {
+		section.CreditHoursHigh = credits
+	}
+
+	for _, attribute := range raw.SectionAttributes {
+		section.Attributes = append(section.Attributes, Attribute{
+			Code:        attribute.Code,
+			Description: attribute.Description,
+			ZTC:         attribute.IsZTCAttribute,
+		})
+	}
+	for _, faculty := range raw.Faculty {
+		section.Instructors = append(section.Instructors, newInstructor(faculty))
+	}
+	for _, meetingFaculty := range raw.MeetingsFaculty {
+		meeting := newMeeting(meetingFaculty)
+		section.Meetings = append(section.Meetings, meeting)
+		// Search results only name the campus of a section; its code comes
+		// with the meetings.
+		if len(section.Campus) == 0 {
+			section.Campus = meeting.Campus
+		}
+	}
+	return section
+}
+
+func newInstructor(faculty Faculty) Instructor {
+	return Instructor{
+		BannerID: faculty.BannerID,
+		Name:     faculty.DisplayName,
+		Email:    faculty.EmailAddress,
+		Primary:  faculty.PrimaryIndicator,
+	}
+}
+
+func newMeeting(meetingFaculty MeetingFaculty) Meeting {
+	meetingTime := meetingFaculty.MeetingTime
+	meeting := Meeting{
+		Begin:               ParseBannerTime(meetingTime.BeginTime),
+		End:                 ParseBannerTime(meetingTime.EndTime),
+		Building:            meetingTime.Building,
+		BuildingDescription: meetingTime.BuildingDescription,
+		Room:                meetingTime.Room,
+		Campus:              meetingTime.Campus,
+		CampusDescription:   meetingTime.CampusDescription,
+		Type:                meetingTime.MeetingType,
+		TypeDescription:     meetingTime.MeetingTypeDescription,
+		ScheduleType:        meetingTime.MeetingScheduleType,
+		HoursWeek:           meetingTime.HoursWeek,
+		CreditHours:         meetingTime.CreditHourSession,
+	}
+	meeting.StartDate, _ = time.Parse(BannerDateLayout, meetingTime.StartDate)
+	meeting.EndDate, _ = time.Parse(BannerDateLayout, meetingTime.EndDate)
+
+	days := []bool{
+		meetingTime.Sunday, meetingTime.Monday, meetingTime.Tuesday, meetingTime.Wednesday,
+		meetingTime.Thursday, meetingTime.Friday, meetingTime.Saturday,
+	}
+	for day, meets := range days {
+		if meets {
+			meeting.Days = append(meeting.Days, time.Weekday(day))
+		}
+	}
+	for _, faculty := range meetingFaculty.Faculty {
+		meeting.Instructors = append(meeting.Instructors, newInstructor(faculty))
+	}
+	return meeting
+}
+
+var weekdayLetters = map[time.Weekday]string{
+	time.Monday:    "M",
+	time.Tuesday:   "T",
+	time.Wednesday: "W",
+	time.Thursday:  "R",
+	time.Friday:    "F",
+	time.Saturday:  "S",
+	time.Sunday:    "U",
+}
+
+// Weekdays are the days a meeting is on. They are written to JSON in Banner's
+// MTWRFSU notation, like "MW".
+type Weekdays []time.Weekday
+
+func (d Weekdays) String() string {
+	var letters strings.Builder
+	for _, day := range d {
+		letters.WriteString(weekdayLetters[day])
+	}
+	return letters.String()
+}
+
+func (d Weekdays) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON reads days in the MTWRFSU notation, or as the weekday numbers
+// that older exports and snapshots hold.
+func (d *Weekdays) UnmarshalJSON(data []byte) error {
+	var numbers []time.Weekday
+	if err := json.Unmarshal(data, &numbers); err == nil {
+		*d = numbers
+		return nil
+	}
+	var letters string
+	if err := json.Unmarshal(data, &letters); err != nil {
+		return InvalidDays
+	}
+	var days Weekdays
+	for _, letter := range strings.ToUpper(letters) {
+		found := false
+		for day, dayLetter := range weekdayLetters {
+			if string(letter) == dayLetter {
+				days = append(days, day)
+				found = true
+			}
+		}
+		if !found {
+			return InvalidDays
+		}
+	}
+	*d = days
+	return nil
+}
+
+// DayLetters returns the meeting days in Banner's MTWRFSU notation.
+func (m Meeting) DayLetters() string {
+	return m.Days.String()
+}
+
+// Scheduled reports whether the meeting happens on set days and times.
+func (m Meeting) Scheduled() bool {
+	return len(m.Days) > 0 && m.Begin.Valid && m.End.Valid && !m.StartDate.IsZero() && !m.EndDate.IsZero()
+}
+
+// FirstOccurrence returns the start of the first class meeting on or after
+// StartDate.
+func (m Meeting) FirstOccurrence(location *time.Location) time.Time {
+	date := m.StartDate
+	for i := 0; i < 7; i++ {
+		for _, day := range m.Days {
+			if date.Weekday() == day {
+				return time.Date(date.Year(), date.Month(), date.Day(), m.Begin.Hour(), m.Begin.Minute(), 0, 0, location)
+			}
+		}
+		date = date.AddDate(0, 0, 1)
+	}
+	return time.Date(m.StartDate.Year(), m.StartDate.Month(), m.StartDate.Day(), m.Begin.Hour(), m.Begin.Minute(), 0, 0, location)
+}
+
+// Overlaps reports whether both meetings happen at the same time on some
+// day: they share a weekday, their date ranges intersect and their times
+// intersect. Unscheduled meetings never overlap.
+func (m Meeting) Overlaps(other Meeting) bool {
+	if !m.Scheduled() || !other.Scheduled() {
+		return false
+	}
+	if m.EndDate.Before(other.StartDate) || other.EndDate.Before(m.StartDate) {
+		return false
+	}
+	if m.End.Minutes <= other.Begin.Minutes || other.End.Minutes <= m.Begin.Minutes {
+		return false
+	}
+	for _, day := range m.Days {
+		for _, otherDay := range other.Days {
+			if day == otherDay {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (m Meeting) Location() string {
+	return strings.TrimSpace(m.Building + " " + m.Room)
+}
+
+func (s Section) InstructorNames() string {
+	var names []string
+	for _, instructor := range s.Instructors {
+		names = append(names, instructor.Name)
+	}
+	return strings.Join(names, "; ")
+}
+
+func (s Section) InstructorEmails() string {
+	var emails []string
+	for _, instructor := range s.Instructors {
+		if len(instructor.Email) > 0 {
+			emails = append(emails, instructor.Email)
+		}
+	}
+	return strings.Join(emails, "; ")
+}
+
+func (s Section) AttributeCodes() string {
+	var codes []string
+	for _, attribute := range s.Attributes {
+		codes = append(codes, attribute.Code)
+	}
+	return strings.Join(codes, "; ")
+}
+
+// onCampus reports whether campus matches the campus of s or of one of its
+// meetings, by code or description.
+func (s Section) onCampus(campus string) bool {
+	if strings.EqualFold(s.Campus, campus) || containsFold(s.CampusDescription, campus) {
+		return true
+	}
+	for _, meeting := range s.Meetings {
+		if strings.EqualFold(meeting.Campus, campus) || containsFold(meeting.CampusDescription, campus) {
+			return true
+		}
+	}
+	return false
+}
+
+// Conflicts reports whether any meeting of s overlaps a meeting of other.
+func (s Section) Conflicts(other Section) bool {
+	for _, meeting := range s.Meetings {
+		for _, otherMeeting := range other.Meetings {
+			if meeting.Overlaps(otherMeeting) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s Section) Key() string {
+	return s.Term + "/" + s.CRN
+}
+
+func formatBannerDate(date time.Time) string {
+	if date.IsZero() {
+		return ""
+	}
+	return date.Format(BannerDateLayout)
+}
+
+func stringValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
+
+func intValue(value any) int {
+	switch v := value.(type) {
+	case float64:
+		return int(v)
+	case string:
+		i, _ := strconv.Atoi(v)
+		return i
+	}
+	return 0
+}
@@ -0,0 +1,84 @@
+package tasks
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/veil/mock"
+)
+
+func TestSearchTaskKeepsSectionDetails(t *testing.T) {
+	server := newMockServer(t)
+	server.Sections = append(server.Sections, mock.Section{
+		Term: "202432", CRN: "30006", Subject: "PHYS", CourseNumber: "4C", Sequence: "01", Title: "PHYSICS FOR SCIENTISTS",
+		Campus: "De Anza", CampusCode: "DA", ScheduleType: "Lecture", CreditHours: 6, Capacity: 40, Enrolled: 40, WaitCapacity: 10, WaitCount: 4,
+		Instructor: "Faraday, Michael", InstructorEmail: "faraday@example.edu", Days: "TR", Begin: "1330", End: "1520",
+		StartDate: "01/08/2024", EndDate: "03/29/2024", Building: "S", Room: "S12", InstructionalMethod: "Hybrid",
+		Attributes: []string{"ZTC", "HON"}, LinkIdentifier: "A1",
+	})
+	task := newMockTask(t, server)
+	task.Criteria = SearchCriteria{CourseNumber: "4C"}
+
+	search := NewSearchTask(task)
+	if err := search.GetCourses(); err != nil {
+		t.Fatal(err)
+	}
+	if len(search.Sections()) != 1 {
+		t.Fatalf("got %d sections, want 1", len(search.Sections()))
+	}
+
+	section := search.Sections()[0]
+	if section.CreditHours != 6 || section.WaitCapacity != 10 || section.WaitCount != 4 || section.Campus != "DA" || section.CampusDescription != "De Anza" {
+		t.Errorf("unexpected section %+v", section)
+	}
+	if section.InstructionalMethod != "Hybrid" || section.AttributeCodes() != "ZTC; HON" || !section.Attributes[0].ZTC {
+		t.Errorf("unexpected attributes %+v", section.Attributes)
+	}
+	if section.LinkIdentifier != "A1" || !section.Linked {
+		t.Errorf("unexpected link %q", section.LinkIdentifier)
+	}
+	if len(section.Instructors) != 1 || section.Instructors[0].Email != "faraday@example.edu" {
+		t.Errorf("unexpected instructors %+v", section.Instructors)
+	}
+	if len(section.Meetings) != 1 {
+		t.Fatalf("got %d meetings, want 1", len(section.Meetings))
+	}
+
+	meeting := section.Meetings[0]
+	if meeting.DayLetters() != "TR" || meeting.Location() != "S S12" {
+		t.Errorf("unexpected meeting %+v", meeting)
+	}
+	if meeting.Begin.Hour() != 13 || meeting.Begin.Minute() != 30 || meeting.End.String() != "3:20 PM" {
+		t.Errorf("unexpected times %v - %v", meeting.Begin, meeting.End)
+	}
+	if !meeting.StartDate.Equal(time.Date(2024, time.January, 8, 0, 0, 0, 0, time.UTC)) || meeting.EndDate.Month() != time.March {
+		t.Errorf("unexpected dates %v - %v", meeting.StartDate, meeting.EndDate)
+	}
+}
+
+func TestMeetingDaysJSON(t *testing.T) {
+	data, err := json.Marshal(Meeting{Days: Weekdays{time.Monday, time.Wednesday}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"days":"MW"`) {
+		t.Errorf("days not written as letters: %s", data)
+	}
+
+	want := Weekdays{time.Monday, time.Wednesday}
+	for _, input := range []string{`{"days":"MW"}`, `{"days":[1,3]}`} {
+		meeting := Meeting{}
+		if err := json.Unmarshal([]byte(input), &meeting); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(meeting.Days, want) {
+			t.Errorf("%s: days = %v", input, meeting.Days)
+		}
+	}
+	if err := json.Unmarshal([]byte(`{"days":"MX"}`), &Meeting{}); err == nil {
+		t.Error("unknown day letter was accepted")
+	}
+}
@@ -0,0 +1,120 @@
+package tasks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	http "github.com/bogdanfinn/fhttp"
+	tls_client "github.com/bogdanfinn/tls-client"
+)
+
+type Session struct {
+	Username      string                    `json:"username"`
+	Cookies       map[string][]*http.Cookie `json:"cookies"`
+	Authenticated []string                  `json:"authenticated"`
+	SavedAt       time.Time                 `json:"savedAt"`
+}
+
+type SessionStore struct {
+	Dir string
+}
+
+func NewSessionStore(dir string) *SessionStore {
+	return &SessionStore{Dir: dir}
+}
+
+func (store *SessionStore) path(username string) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, username)
+	return filepath.Join(store.Dir, fmt.Sprintf("%s.json", name))
+}
+
+func (store *SessionStore) Load(username string) (*Session, error) {
+	data, err := os.ReadFile(store.path(username))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, NoSavedSession
+	} else if err != nil {
+		return nil, FailedLoadingSession
+	}
+
+	session := Session{}
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, UnableToParseJSON
+	}
+	if session.Username != username {
+		return nil, NoSavedSession
+	}
+	return &session, nil
+}
+
+func (store *SessionStore) Save(session *Session) error {
+	if err := os.MkdirAll(store.Dir, 0700); err != nil {
+		return FailedSavingSession
+	}
+
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return UnableToParseJSON
+	}
+
+	if err := os.WriteFile(store.path(session.Username), data, 0600); err != nil {
+		return FailedSavingSession
+	}
+	return nil
+}
+
+func (store *SessionStore) Delete(username string) error {
+	if err := os.Remove(store.path(username)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return FailedSavingSession
+	}
+	return nil
+}
+
+func (task *Task) cookieJar() (tls_client.CookieJar, bool) {
+	jar, ok := task.Client.GetCookieJar().(tls_client.CookieJar)
+	return jar, ok
+}
+
+func (task *Task) SaveSession(authenticated []string) error {
+	if task.SessionStore == nil {
+		return nil
+	}
+	jar, ok := task.cookieJar()
+	if !ok {
+		return FailedSavingSession
+	}
+
+	session := &Session{
+		Username:      task.Username,
+		Cookies:       jar.GetAllCookies(),
+		Authenticated: authenticated,
+		SavedAt:       time.Now(),
+	}
+	return task.SessionStore.Save(session)
+}
+
+func (task *Task) RestoreSession() (*Session, error) {
+	if task.SessionStore == nil {
+		return nil, NoSavedSession
+	}
+	session, err := task.SessionStore.Load(task.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	for host, cookies := range session.Cookies {
+		task.Client.SetCookies(&url.URL{Scheme: "https", Host: host}, cookies)
+	}
+	return session, nil
+}
@@ -5,67 +5,150 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"net/url"
 	"regexp"
 	"strings"
 	"time"
 
-	"github.com/PuerkitoBio/goquery"
 	http "github.com/bogdanfinn/fhttp"
 )
 
 type SignupTask struct {
-	task         *Task
-	AuthToken    string
-	RelayState   string
-	SAMLResponse string
-	SAMLRequest  string
-	Model        map[string]interface{}
+	task *Task
+	// Cart holds the registration model of every CRN added since the last
+	// submit, in the order they were added.
+	Cart []map[string]interface{}
+	// Current holds the rows of the sections already registered, as fetched
+	// by GetRegistrationCart.
+	Current []map[string]interface{}
+	// Groups are the CRNs to add, each with the linked sections it has to be
+	// registered with, as resolved by ResolveLinkedSections.
+	Groups     [][]string
+	Results    []RegistrationResult
+	Registered []Section
+	// Schedule is the check of the latest round made before adding it.
+	Schedule *ScheduleReport
+	// Chosen holds, for every course in choices, the alternate that was
+	// registered, or an empty string when none was.
+	Chosen []string
+	round  []string
+	tried  map[string]bool
+	// waitlistTried holds the CRNs tried in the waitlist pass of
+	// EnrollChoices, and seatsOnly keeps SubmitChanges from joining waitlists
+	// before it.
+	waitlistTried map[string]bool
+	seatsOnly     bool
 }
 
-func (s *SignupTask) VisitHomepage() error {
-	fmt.Println("Visiting homepage")
+// RegistrationResult is what happened to one CRN, either when adding it to
+// the cart or when the cart was submitted.
+type RegistrationResult struct {
+	CRN    string   `json:"crn"`
+	Title  string   `json:"title"`
+	Status string   `json:"status"`
+	Errors []string `json:"errors"`
+	// WaitPosition is the place on the waitlist, when Banner reports it.
+	WaitPosition int `json:"waitPosition,omitempty"`
+}
 
-	request, err := http.NewRequest(http.MethodGet, "https://ssb-prod.ec.fhda.edu/ssomanager/saml/login?relayState=%2Fc%2Fauth%2FSSB%3Fpkg%3Dhttps%3A%2F%2Fssb-prod.ec.fhda.edu%2FPROD%2Ffhda_uportal.P_DeepLink_Post%3Fp_page%3Dbwskfreg.P_AltPin%26p_payload%3De30%3D", nil)
-	if err != nil {
-		return FailedToCreateRequest
-	}
-	request.Header.Add("accept", "*/*")
-	request.Header.Add("accept-language", "en-US,en;q=0.9")
-	request.Header.Add("user-agent", s.task.UserAgent)
+const (
+	RegisteredStatus    = "Registered"
+	NotAddedStatus      = "Not Added"
+	NotRegisteredStatus = "Not Registered"
+	DroppedStatus       = "Deleted"
+	WaitlistedStatus    = "Wait Listed"
+	// DropNotAllowedStatus is recorded when Banner does not offer the drop
+	// action for a registered section.
+	DropNotAllowedStatus = "Drop Not Allowed"
+	// ScheduleConflictStatus is recorded for alternates that were skipped
+	// because they overlap the sections already enrolled in.
+	ScheduleConflictStatus = "Schedule Conflict"
+	// WouldAddStatus is recorded in a dry run for CRNs that were added to the
+	// cart without errors.
+	WouldAddStatus = "Would Add"
+	// DropAction is the registration action Banner offers to drop a section
+	// on the web.
+	DropAction     = "DW"
+	WaitlistAction = "WL"
+)
 
-	resp, err := s.task.Client.Do(request)
-	if err != nil {
-		return FailedToMakeRequest
-	}
-	defer resp.Body.Close()
+// Swap drops one CRN for another without giving up the first when the
+// second cannot be added.
+type Swap struct {
+	Drop string
+	Add  string
+}
 
-	if resp.StatusCode != 200 {
-		return UnknownHTTPResponseStatus
+// ParseSwaps parses comma separated DROP:ADD CRN pairs.
+func ParseSwaps(value string) ([]Swap, error) {
+	var swaps []Swap
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if len(pair) == 0 {
+			continue
+		}
+		drop, add, ok := strings.Cut(pair, ":")
+		drop, add = strings.TrimSpace(drop), strings.TrimSpace(add)
+		if !ok || len(drop) == 0 || len(add) == 0 {
+			return nil, InvalidSwap
+		}
+		swaps = append(swaps, Swap{Drop: drop, Add: add})
 	}
+	return swaps, nil
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return FailedToReadResponseBody
+// ParseCourseChoices parses comma separated courses, each a list of CRNs
+// separated by "|" in order of preference, like "30001|30002,40001".
+func ParseCourseChoices(value string) [][]string {
+	var choices [][]string
+	for _, course := range strings.Split(value, ",") {
+		var choice []string
+		for _, crn := range strings.Split(course, "|") {
+			if crn = strings.TrimSpace(crn); len(crn) > 0 {
+				choice = append(choice, crn)
+			}
+		}
+		if len(choice) > 0 {
+			choices = append(choices, choice)
+		}
 	}
-	if len(body) > 0 {
+	return choices
+}
+
+func (s *SignupTask) recordResult(result RegistrationResult) {
+	for i, existing := range s.Results {
+		if existing.CRN == result.CRN {
+			s.Results[i] = result
+			return
+		}
 	}
-	return nil
+	s.Results = append(s.Results, result)
 }
 
-func (s *SignupTask) Login() error {
-	fmt.Println("Logging in")
+// addToCart adds model to the cart, replacing the model of the same CRN if it
+// was already added.
+func (s *SignupTask) addToCart(crn string, model map[string]interface{}) {
+	for i, existing := range s.Cart {
+		if stringValue(existing["courseReferenceNumber"]) == crn {
+			s.Cart[i] = model
+			return
+		}
+	}
+	s.Cart = append(s.Cart, model)
+}
 
-	s.task.LoginAttempts++
+func (s *SignupTask) SaveTerm() error {
+	fmt.Println("Saving Term")
 
-	loginData := fmt.Sprintf("j_username=%s&j_password=%s&_eventId_proceed=", s.task.Username, s.task.Password)
-	request, err := http.NewRequest(http.MethodPost, fmt.Sprintf("https://ssoshib.fhda.edu/idp/profile/SAML2/Redirect/SSO?execution=e1s%d", s.task.LoginAttempts), bytes.NewBufferString(loginData))
+	url := s.task.Institution.Registration(fmt.Sprintf(
+		"/ssb/term/saveTerm?mode=registration&term=%s",
+		s.task.TermId,
+	))
+	request, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
 		return FailedToCreateRequest
 	}
 	request.Header.Add("accept", "*/*")
 	request.Header.Add("accept-language", "en-US,en;q=0.9")
-	request.Header.Add("content-type", "application/x-www-form-urlencoded")
 	request.Header.Add("user-agent", s.task.UserAgent)
 
 	resp, err := s.task.Client.Do(request)
@@ -74,62 +157,46 @@ func (s *SignupTask) Login() error {
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return UnknownHTTPResponseStatus
-	}
-
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return FailedToReadResponseBody
 	}
-	reader := strings.NewReader(string(body))
-	document, err := goquery.NewDocumentFromReader(reader)
-	if err != nil {
-		return UnableToGetDocument
-	}
-	var message string
-	document.Find("div[class='alert alert-danger']").Each(func(index int, element *goquery.Selection) {
-		message = strings.TrimSpace(element.Text())
-	})
-
-	if err := s.task.handleLoginMessage(message); err != nil {
+	if err := s.task.checkSession(resp, body); err != nil {
 		return err
 	}
 
-	relayStateValue := ""
-	samlResponseValue := ""
-	document.Find("input[name='SAMLResponse']").Each(func(index int, element *goquery.Selection) {
-		value, exists := element.Attr("value")
-		if exists {
-			samlResponseValue = value
-		}
-	})
-	document.Find("input[name='RelayState']").Each(func(index int, element *goquery.Selection) {
-		value, exists := element.Attr("value")
-		if exists {
-			relayStateValue = value
+	if len(body) > 0 {
+		if !strings.Contains(string(body), s.task.TermId) {
+			return FailedSavingTerm
 		}
-	})
-
-	if len(samlResponseValue) == 0 {
-		return NoSamlResponseValue
 	}
-	s.RelayState = relayStateValue
-	s.SAMLResponse = samlResponseValue
 	return nil
 }
 
-func (s *SignupTask) SubmitCommonAuth() error {
-	fmt.Println("Submitting Common Auth SSO")
+func (s *SignupTask) GetRegistrationStatus() error {
+	fmt.Println("Getting registration status")
+
+	opensAt, err := s.registrationOpensAt()
+	if err != nil || opensAt.IsZero() {
+		return err
+	}
 
-	values := url.Values{
-		"RelayState":   {s.RelayState},
-		"SAMLResponse": {s.SAMLResponse},
+	fmt.Printf("Registration opens at: %s\n", opensAt)
+	if s.task.DryRun {
+		fmt.Println("Dry run, not waiting for registration to open")
+		return nil
 	}
+	return s.WaitForRegistration(opensAt)
+}
 
-	request, err := http.NewRequest(http.MethodPost, "https://eis-prod.ec.fhda.edu/commonauth", bytes.NewBufferString(values.Encode()))
+// registrationOpensAt checks the registration status of the term. It returns
+// when registration opens if Banner says it has not yet, or the zero time
+// when the student can register now.
+func (s *SignupTask) registrationOpensAt() (time.Time, error) {
+	termData := fmt.Sprintf("term=%s&studyPath=&studyPathText=&startDatepicker=&endDatepicker=&uniqueSessionId=", s.task.TermId)
+	request, err := http.NewRequest(http.MethodPost, s.task.Institution.Registration("/ssb/term/search?mode=registration"), bytes.NewBufferString(termData))
 	if err != nil {
-		return FailedToCreateRequest
+		return time.Time{}, FailedToCreateRequest
 	}
 	request.Header.Add("accept", "*/*")
 	request.Header.Add("accept-language", "en-US,en;q=0.9")
@@ -138,61 +205,64 @@ func (s *SignupTask) SubmitCommonAuth() error {
 
 	resp, err := s.task.Client.Do(request)
 	if err != nil {
-		return FailedToMakeRequest
+		return time.Time{}, FailedToMakeRequest
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return UnknownHTTPResponseStatus
-	}
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return FailedToReadResponseBody
+		return time.Time{}, FailedToReadResponseBody
 	}
-	reader := strings.NewReader(string(body))
-	document, err := goquery.NewDocumentFromReader(reader)
-	if err != nil {
-		return UnableToGetDocument
+	if err := s.task.checkSession(resp, body); err != nil {
+		return time.Time{}, err
 	}
 
-	relayStateValue := ""
-	samlResponseValue := ""
-	document.Find("input[name='SAMLResponse']").Each(func(index int, element *goquery.Selection) {
-		value, exists := element.Attr("value")
-		if exists {
-			samlResponseValue = value
-		}
-	})
-	document.Find("input[name='RelayState']").Each(func(index int, element *goquery.Selection) {
-		value, exists := element.Attr("value")
-		if exists {
-			relayStateValue = value
-		}
-	})
+	if resp.StatusCode != 200 {
+		return time.Time{}, UnknownHTTPResponseStatus
+	}
 
-	if len(samlResponseValue) == 0 {
-		return NoSamlResponseValue
+	registrationStatus := RegistrationStatus{}
+	if err := json.Unmarshal(body, &registrationStatus); err != nil {
+		fmt.Println(err)
+		return time.Time{}, UnableToParseJSON
+	}
+	if len(registrationStatus.StudentEligFailures) == 0 {
+		return time.Time{}, nil
 	}
-	s.RelayState = relayStateValue
-	s.SAMLResponse = samlResponseValue
-	return nil
-}
 
-func (s *SignupTask) SubmitSSOManager() error {
-	fmt.Println("Submitting SSO Manager")
+	var hasRegistrationTime bool
+	var failure string
+	for _, _failure := range registrationStatus.StudentEligFailures {
+		if strings.Contains(_failure, "You can register from") {
+			hasRegistrationTime = true
+			failure = _failure
+		}
+	}
+	if !hasRegistrationTime {
+		return time.Time{}, NotEligibleToRegister
+	}
 
-	values := url.Values{
-		"RelayState":   {s.RelayState},
-		"SAMLResponse": {s.SAMLResponse},
+	regex := regexp.MustCompile(`\d{2}/\d{2}/\d{4} \d{2}:\d{2} [APM]{2}`)
+	matches := regex.FindAllString(failure, -1)
+	if len(matches) == 0 {
+		return time.Time{}, nil
+	}
+	targetTime, err := time.ParseInLocation("01/02/2006 03:04 PM", matches[0], s.task.Institution.Location())
+	if err != nil {
+		return time.Time{}, FailedParsingDate
 	}
+	return targetTime, nil
+}
+
+func (s *SignupTask) VisitClassRegistration() error {
+	fmt.Println("Visiting class registration")
 
-	request, err := http.NewRequest(http.MethodPost, "https://ssb-prod.ec.fhda.edu/ssomanager/saml/SSO", bytes.NewBufferString(values.Encode()))
+	request, err := http.NewRequest(http.MethodHead, s.task.Institution.Registration("/ssb/classRegistration/classRegistration"), nil)
 	if err != nil {
 		return FailedToCreateRequest
 	}
 	request.Header.Add("accept", "*/*")
 	request.Header.Add("accept-language", "en-US,en;q=0.9")
-	request.Header.Add("content-type", "application/x-www-form-urlencoded")
 	request.Header.Add("user-agent", s.task.UserAgent)
 
 	resp, err := s.task.Client.Do(request)
@@ -205,15 +275,24 @@ func (s *SignupTask) SubmitSSOManager() error {
 	if err != nil {
 		return FailedToReadResponseBody
 	}
+	if err := s.task.checkSession(resp, body); err != nil {
+		return err
+	}
+
 	if len(body) > 0 {
 
 	}
 	return nil
 }
 
-func (s *SignupTask) RegisterPostSignIn() error {
-	fmt.Println("Registering post sign in")
-	request, err := http.NewRequest(http.MethodGet, "https://reg-prod.ec.fhda.edu/StudentRegistrationSsb/ssb/registration/registerPostSignIn?mode=registration", nil)
+func (s *SignupTask) AddCourse(CourseNumber string) error {
+	fmt.Printf("Adding course %s\n", CourseNumber)
+
+	url := s.task.Institution.Registration(fmt.Sprintf(
+		"/ssb/classRegistration/addRegistrationItem?term=%s&courseReferenceNumber=%s&olr=false",
+		s.task.TermId, CourseNumber,
+	))
+	request, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
 		return FailedToCreateRequest
 	}
@@ -227,163 +306,342 @@ func (s *SignupTask) RegisterPostSignIn() error {
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return UnknownHTTPResponseStatus
-	}
-
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return FailedToReadResponseBody
 	}
-	reader := strings.NewReader(string(body))
-	document, err := goquery.NewDocumentFromReader(reader)
-	if err != nil {
-		return UnableToGetDocument
+	if err := s.task.checkSession(resp, body); err != nil {
+		return err
+	}
+
+	if resp.StatusCode != 200 {
+		return UnknownHTTPResponseStatus
 	}
-	samlRequestValue := ""
-	document.Find("input[name='SAMLRequest']").Each(func(index int, element *goquery.Selection) {
-		value, exists := element.Attr("value")
-		if exists {
-			samlRequestValue = value
-		}
-	})
 
-	s.SAMLRequest = samlRequestValue
+	addCourse := AddCourse{}
+	if err := json.Unmarshal(body, &addCourse); err != nil {
+		fmt.Println(err)
+		return UnableToParseJSON
+	}
+	if addCourse.Success {
+		dataModel, err := extractModel([]byte(body))
+		if err != nil {
+			fmt.Println(err)
+			return UnableToParseJSON
+		}
+		s.addToCart(CourseNumber, dataModel)
+	} else {
+		result := RegistrationResult{CRN: CourseNumber, Status: NotAddedStatus}
+		if len(addCourse.Message) > 0 {
+			fmt.Printf("Error adding course %s: %s\n", CourseNumber, addCourse.Message)
+			result.Errors = append(result.Errors, addCourse.Message)
+		}
+		s.recordResult(result)
+		return FailedToAddCourse
+	}
 	return nil
 }
 
-func (s *SignupTask) SubmitSamIsso() error {
-	fmt.Println("Submitting Sam Isso")
-
-	values := url.Values{
-		"SAMLRequest": {s.SAMLRequest},
-	}
+// AddCourses adds every CRN to the cart, together with the linked sections
+// resolved for it. A group Banner rejects part of is reported and left out;
+// it is only an error when nothing could be added. Request and parse errors
+// are returned so the step is retried.
+func (s *SignupTask) AddCourses() error {
+	fmt.Println("Adding courses")
 
-	request, err := http.NewRequest(http.MethodPost, "https://eis-prod.ec.fhda.edu/samlsso", bytes.NewBufferString(values.Encode()))
-	if err != nil {
-		return FailedToCreateRequest
+	groups := s.Groups
+	if groups == nil {
+		for _, course := range s.coursesToAdd() {
+			if len(course) > 0 {
+				groups = append(groups, []string{course})
+			}
+		}
 	}
-	request.Header.Add("accept", "*/*")
-	request.Header.Add("accept-language", "en-US,en;q=0.9")
-	request.Header.Add("content-type", "application/x-www-form-urlencoded")
-	request.Header.Add("user-agent", s.task.UserAgent)
 
-	resp, err := s.task.Client.Do(request)
-	if err != nil {
-		return FailedToMakeRequest
-	}
-	defer resp.Body.Close()
+	s.Cart = nil
+	var failed []string
+	for _, group := range groups {
+		var missing string
+		for _, course := range group {
+			if err := s.AddCourse(course); err == FailedToAddCourse {
+				missing = course
+				break
+			} else if err != nil {
+				return err
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
 
-	if resp.StatusCode != 200 {
-		return UnknownHTTPResponseStatus
+		failed = append(failed, group...)
+		var added []map[string]interface{}
+		for _, course := range group {
+			if course != missing {
+				if model := s.removeFromCart(course); model != nil {
+					added = append(added, model)
+				}
+				s.recordResult(RegistrationResult{
+					CRN:    course,
+					Status: NotAddedStatus,
+					Errors: []string{fmt.Sprintf("Linked section %s could not be added", missing)},
+				})
+			}
+		}
+		// Take the rest of the group out of Banner's cart too, so it is not
+		// submitted with a later batch.
+		if len(added) > 0 {
+			if _, err := s.submit(BatchUpdate{Destroy: added}); err != nil {
+				return err
+			}
+		}
 	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return FailedToReadResponseBody
+	if len(failed) > 0 {
+		fmt.Printf("Could not add %s\n", strings.Join(failed, ", "))
 	}
-	reader := strings.NewReader(string(body))
-	document, err := goquery.NewDocumentFromReader(reader)
-	if err != nil {
-		return UnableToGetDocument
+	if len(s.Cart) == 0 {
+		return FailedToAddCourse
 	}
-	samlResponseValue := ""
-	document.Find("input[name='SAMLResponse']").Each(func(index int, element *goquery.Selection) {
-		value, exists := element.Attr("value")
-		if exists {
-			samlResponseValue = value
-		}
-	})
+	return nil
+}
 
-	if len(samlResponseValue) == 0 {
-		return NoSamlResponseValue
+// removeFromCart removes crn from the local cart and returns its model, or
+// nil when it was not in the cart.
+func (s *SignupTask) removeFromCart(crn string) map[string]interface{} {
+	for i, model := range s.Cart {
+		if stringValue(model["courseReferenceNumber"]) == crn {
+			s.Cart = append(s.Cart[:i], s.Cart[i+1:]...)
+			return model
+		}
 	}
-
-	s.SAMLResponse = samlResponseValue
 	return nil
 }
 
-func (s *SignupTask) SubmitSSBSp() error {
-	fmt.Println("Submitting SSB Sp")
+func (s *SignupTask) SubmitChanges() error {
+	fmt.Printf("Submitting %d changes\n", len(s.Cart))
 
-	values := url.Values{
-		"SAMLResponse": {s.SAMLResponse},
+	if len(s.Cart) == 0 {
+		return EmptyCart
 	}
-
-	request, err := http.NewRequest(http.MethodPost, "https://reg-prod.ec.fhda.edu/StudentRegistrationSsb/saml/SSO/alias/registrationssb-prod-sp", bytes.NewBufferString(values.Encode()))
+	rows, err := s.submit(BatchUpdate{Update: s.Cart})
 	if err != nil {
-		return FailedToCreateRequest
+		return err
 	}
-	request.Header.Add("accept", "*/*")
-	request.Header.Add("accept-language", "en-US,en;q=0.9")
-	request.Header.Add("content-type", "application/x-www-form-urlencoded")
-	request.Header.Add("user-agent", s.task.UserAgent)
+	s.Cart = nil
 
-	resp, err := s.task.Client.Do(request)
-	if err != nil {
-		return FailedToMakeRequest
+	if !s.task.AllowWaitlist || s.seatsOnly {
+		return nil
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return UnknownHTTPResponseStatus
+	waitlist := s.waitlistRows(rows)
+	if len(waitlist) == 0 {
+		return nil
 	}
+	fmt.Printf("Joining %d waitlists\n", len(waitlist))
+	_, err = s.submit(BatchUpdate{Update: waitlist})
+	return err
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return FailedToReadResponseBody
+// waitlistRows returns the rows that failed only because the section is
+// full, with the waitlist action selected, for the ones Banner offers a
+// waitlist for.
+func (s *SignupTask) waitlistRows(rows []map[string]interface{}) []map[string]interface{} {
+	var waitlist []map[string]interface{}
+	for _, row := range rows {
+		if row["statusDescription"] != "Errors Preventing Registration" {
+			continue
+		}
+		crnErrors, _ := row["crnErrors"].([]interface{})
+		if len(crnErrors) == 0 {
+			continue
+		}
+		full := true
+		for _, crnError := range crnErrors {
+			crnError, _ := crnError.(map[string]interface{})
+			message, _ := crnError["message"].(string)
+			if !isWaitlistError(message) {
+				full = false
+			}
+		}
+		if !full {
+			continue
+		}
+		action := registrationAction(row, WaitlistAction)
+		if action == nil {
+			continue
+		}
+		waitlisted := map[string]interface{}{}
+		for key, value := range row {
+			waitlisted[key] = value
+		}
+		waitlisted["selectedAction"] = action
+		waitlist = append(waitlist, waitlisted)
 	}
-	if len(body) > 0 {
+	return waitlist
+}
 
+// waitlistError matches the CRN errors Banner gives a full section that
+// still takes waitlist registrations, "Closed - 3 Waitlisted" and "Open -
+// Reserved for Waitlist", but not rejections like "Waitlist Full".
+var waitlistError = regexp.MustCompile(`(?i)^(closed - \d+ waitlisted|open - reserved for waitlist)$`)
+
+// isWaitlistError reports whether a CRN error means the section is full but
+// still has a waitlist.
+func isWaitlistError(message string) bool {
+	return waitlistError.MatchString(strings.TrimSpace(message))
+}
+
+// registrationAction returns the action with the given registration status
+// that Banner offers for row, or nil.
+func registrationAction(row map[string]interface{}, status string) map[string]interface{} {
+	actions, _ := row["registrationActions"].([]interface{})
+	for _, action := range actions {
+		action, ok := action.(map[string]interface{})
+		if ok && action["courseRegistrationStatus"] == status {
+			return action
+		}
 	}
 	return nil
 }
 
-func (s *SignupTask) SaveTerm() error {
-	fmt.Println("Saving Term")
+// submit posts batch, records the outcome of every CRN in it and returns the
+// rows Banner sent back.
+func (s *SignupTask) submit(batch BatchUpdate) ([]map[string]interface{}, error) {
+	if s.task.DryRun && (len(batch.Create) > 0 || len(batch.Update) > 0) {
+		return nil, DryRunSubmit
+	}
+	payloadJson, err := json.MarshalIndent(batch, "", "  ")
+	if err != nil {
+		fmt.Println(err)
+		return nil, UnableToParseJSON
+	}
 
-	url := fmt.Sprintf(
-		"https://reg-prod.ec.fhda.edu/StudentRegistrationSsb/ssb/term/saveTerm?mode=registration&term=%s",
-		s.task.TermId,
-	)
-	request, err := http.NewRequest(http.MethodGet, url, nil)
+	request, err := http.NewRequest(http.MethodPost, s.task.Institution.Registration("/ssb/classRegistration/submitRegistration/batch"), bytes.NewBufferString(string(payloadJson)))
 	if err != nil {
-		return FailedToCreateRequest
+		return nil, FailedToCreateRequest
 	}
 	request.Header.Add("accept", "*/*")
 	request.Header.Add("accept-language", "en-US,en;q=0.9")
+	request.Header.Add("content-type", "application/json")
 	request.Header.Add("user-agent", s.task.UserAgent)
 
 	resp, err := s.task.Client.Do(request)
 	if err != nil {
-		return FailedToMakeRequest
+		return nil, FailedToMakeRequest
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return FailedToReadResponseBody
+		return nil, FailedToReadResponseBody
+	}
+	if err := s.task.checkSession(resp, body); err != nil {
+		return nil, err
 	}
 
-	if len(body) > 0 {
-		if !strings.Contains(string(body), s.task.TermId) {
-			return FailedSavingTerm
+	if resp.StatusCode != 200 {
+		return nil, UnknownHTTPResponseStatus
+	}
+	changes := Changes{}
+	if err := json.Unmarshal(body, &changes); err != nil {
+		fmt.Println(err)
+		return nil, UnableToParseJSON
+	}
+	rows := struct {
+		Data struct {
+			Update []map[string]interface{} `json:"update"`
+		} `json:"data"`
+	}{}
+	if err := json.Unmarshal(body, &rows); err != nil {
+		fmt.Println(err)
+		return nil, UnableToParseJSON
+	}
+
+	submitted := map[string]bool{}
+	for _, model := range batch.Update {
+		submitted[stringValue(model["courseReferenceNumber"])] = true
+	}
+
+	for _, data := range changes.Data.Update {
+		crn := stringValue(data.CourseReferenceNumber)
+		if !submitted[crn] {
+			continue
+		}
+		result := RegistrationResult{CRN: crn, Title: data.CourseTitle, Status: data.StatusDescription, WaitPosition: intValue(data.WaitPosition)}
+		for _, crnError := range data.CrnErrors {
+			result.Errors = append(result.Errors, crnError.Message)
+		}
+		s.recordResult(result)
+
+		if len(data.CrnErrors) > 0 || data.StatusDescription == "Errors Preventing Registration" {
+			fmt.Printf("%d Errors encountered while changing %s - %s\n", len(data.CrnErrors), crn, data.CourseTitle)
+			for _, error := range data.CrnErrors {
+				fmt.Printf("Error received: %s\n", error.Message)
+			}
+		}
+
+		switch {
+		case data.StatusDescription == DroppedStatus:
+			fmt.Printf("Dropped %s - %s\n", crn, data.CourseTitle)
+			for i, section := range s.Registered {
+				if section.CRN == crn {
+					s.Registered = append(s.Registered[:i], s.Registered[i+1:]...)
+					break
+				}
+			}
+		case data.StatusDescription == WaitlistedStatus:
+			fmt.Printf("Waitlisted for %s - %s at position %d\n", crn, data.CourseTitle, result.WaitPosition)
+			s.task.sendWaitlistNotification(data.CourseTitle, result.WaitPosition)
+		case data.StatusDescription == RegisteredStatus && !s.holds(crn):
+			fmt.Printf("Successfully registered for %s - %s\n", crn, data.CourseTitle)
+			s.Registered = append(s.Registered, Section{
+				Term:                           data.Term,
+				CRN:                            crn,
+				Subject:                        data.Subject,
+				CourseNumber:                   data.CourseNumber,
+				SequenceNumber:                 stringValue(data.SequenceNumber),
+				Title:                          data.CourseTitle,
+				PartOfTerm:                     data.PartOfTerm,
+				Campus:                         data.Campus,
+				ScheduleType:                   data.ScheduleDescription,
+				InstructionalMethodDescription: data.InstructionalMethodDescription,
+			})
+			s.task.sendSuccessfulEnrollmentNotification(data.CourseTitle)
+		}
+	}
+	return rows.Data.Update, nil
+}
+
+// holds reports whether crn was already registered before this run, from the
+// rows fetched by GetRegistrationCart.
+func (s *SignupTask) holds(crn string) bool {
+	return s.currentRow(crn) != nil
+}
+
+func (s *SignupTask) currentRow(crn string) map[string]interface{} {
+	for _, row := range s.Current {
+		if stringValue(row["courseReferenceNumber"]) == crn {
+			return row
 		}
 	}
 	return nil
 }
 
-func (s *SignupTask) GetRegistrationStatus() error {
-	fmt.Println("Getting registration status")
+// GetRegistrationCart fetches the rows of the sections already registered
+// for the term, which are what a drop has to submit.
+func (s *SignupTask) GetRegistrationCart() error {
+	fmt.Println("Getting registration cart")
 
-	termData := fmt.Sprintf("term=%s&studyPath=&studyPathText=&startDatepicker=&endDatepicker=&uniqueSessionId=", s.task.TermId)
-	request, err := http.NewRequest(http.MethodPost, "https://reg-prod.ec.fhda.edu/StudentRegistrationSsb/ssb/term/search?mode=registration", bytes.NewBufferString(termData))
+	url := s.task.Institution.Registration(fmt.Sprintf(
+		"/ssb/classRegistration/getRegistrationCart?term=%s",
+		s.task.TermId,
+	))
+	request, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
 		return FailedToCreateRequest
 	}
 	request.Header.Add("accept", "*/*")
 	request.Header.Add("accept-language", "en-US,en;q=0.9")
-	request.Header.Add("content-type", "application/x-www-form-urlencoded")
 	request.Header.Add("user-agent", s.task.UserAgent)
 
 	resp, err := s.task.Client.Do(request)
@@ -392,234 +650,435 @@ func (s *SignupTask) GetRegistrationStatus() error {
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return UnknownHTTPResponseStatus
-	}
-
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return FailedToReadResponseBody
 	}
+	if err := s.task.checkSession(resp, body); err != nil {
+		return err
+	}
 
-	registrationStatus := RegistrationStatus{}
-	if err := json.Unmarshal(body, &registrationStatus); err != nil {
+	if resp.StatusCode != 200 {
+		return UnknownHTTPResponseStatus
+	}
+	var rows []map[string]interface{}
+	if err := json.Unmarshal(body, &rows); err != nil {
 		fmt.Println(err)
 		return UnableToParseJSON
 	}
-	if len(registrationStatus.StudentEligFailures) > 0 {
-		var hasRegistrationTime bool
-		var failure string
-		for _, _failure := range registrationStatus.StudentEligFailures {
-			if strings.Contains(_failure, "You can register from") {
-				hasRegistrationTime = true
-				failure = _failure
-			}
-		}
+	s.Current = rows
+	return nil
+}
 
-		if hasRegistrationTime {
-			regex := regexp.MustCompile(`\d{2}/\d{2}/\d{4} \d{2}:\d{2} [APM]{2}`)
-			matches := regex.FindAllString(failure, -1)
+// dropRow returns the registered row of crn with the web drop action
+// selected, or nil when crn is not registered or cannot be dropped.
+func (s *SignupTask) dropRow(crn string) map[string]interface{} {
+	current := s.currentRow(crn)
+	if current == nil {
+		fmt.Printf("Not registered for %s\n", crn)
+		s.recordResult(RegistrationResult{CRN: crn, Status: NotRegisteredStatus})
+		return nil
+	}
+	action := registrationAction(current, DropAction)
+	if action == nil {
+		fmt.Printf("Dropping %s is not allowed\n", crn)
+		s.recordResult(RegistrationResult{CRN: crn, Status: DropNotAllowedStatus})
+		return nil
+	}
+	row := map[string]interface{}{}
+	for key, value := range current {
+		row[key] = value
+	}
+	row["selectedAction"] = action
+	return row
+}
 
-			if len(matches) > 0 {
-				loc, _ := time.LoadLocation("America/Los_Angeles")
-				targetTime, err := time.ParseInLocation("01/02/2006 03:04 PM", matches[0], loc)
-				if err != nil {
-					return FailedParsingDate
-				}
+func (s *SignupTask) DropCourses() error {
+	fmt.Println("Dropping courses")
 
-				now := time.Now().In(loc)
-				fmt.Printf("Registration opens at: %s\n", targetTime)
-				if now.Before(targetTime) {
+	var rows []map[string]interface{}
+	for _, crn := range s.task.CoursesToDrop {
+		if len(crn) == 0 {
+			continue
+		}
+		if row := s.dropRow(crn); row != nil {
+			rows = append(rows, row)
+		}
+	}
+	if len(rows) == 0 {
+		fmt.Println("Nothing to drop")
+		return nil
+	}
+	_, err := s.submit(BatchUpdate{Update: rows})
+	return err
+}
 
-					timeToWait := targetTime.Sub(now) + 5*time.Second
+// SwapCourses drops each swap's Drop CRN for its Add CRN and the linked
+// sections the add requires. All of them go in one conditional submission,
+// so Banner keeps the dropped seat when an add fails. A swap whose adds
+// cannot even be put in the cart is not submitted.
+func (s *SignupTask) SwapCourses() error {
+	fmt.Println("Swapping courses")
 
-					resumeDate := now.Add(timeToWait)
-					fmt.Printf("Will continue after: %s\n", resumeDate.Format("2006-01-02 03:04:05 -0700 MST"))
-					fmt.Printf("Waiting %s or %s to continue\n", formatDuration(timeToWait), timeToWait)
-					time.Sleep(timeToWait)
-					return s.GetRegistrationStatus()
-				} else {
-					fmt.Println("Past registration time")
-				}
+	for _, swap := range s.task.CoursesToSwap {
+		if result, ok := s.result(swap.Add); ok && result.Status == RegisteredStatus {
+			continue
+		}
+		fmt.Printf("Swapping %s for %s\n", swap.Drop, swap.Add)
+
+		drop := s.dropRow(swap.Drop)
+		if drop == nil {
+			continue
+		}
+		// The add goes in with the linked sections it needs.
+		s.round = []string{swap.Add}
+		err := s.ResolveLinkedSections()
+		if err == nil {
+			err = s.AddCourses()
+		}
+		s.round = nil
+		if err == SessionExpired {
+			return err
+		} else if err != nil {
+			fmt.Printf("Keeping %s, %s could not be added\n", swap.Drop, swap.Add)
+			continue
+		}
+
+		batch := BatchUpdate{
+			Update:             append(s.Cart, drop),
+			ConditionalAddDrop: true,
+		}
+		if _, err := s.submit(batch); err != nil {
+			return err
+		}
+		s.Cart = nil
+
+		added, _ := s.result(swap.Add)
+		dropped, _ := s.result(swap.Drop)
+		if added.Status != RegisteredStatus && dropped.Status == DroppedStatus {
+			fmt.Printf("Warning: dropped %s but %s was not added\n", swap.Drop, swap.Add)
+			if err := s.task.sendNotification("Swap Failed", fmt.Sprintf("Dropped %s but could not add %s", swap.Drop, swap.Add)); err != nil {
+				fmt.Println(err)
 			}
-		} else {
-			return NotEligibleToRegister
 		}
 	}
 	return nil
 }
 
-func (s *SignupTask) VisitClassRegistration() error {
-	fmt.Println("Visiting class registration")
+func (s *SignupTask) result(crn string) (RegistrationResult, bool) {
+	for _, result := range s.Results {
+		if result.CRN == crn {
+			return result, true
+		}
+	}
+	return RegistrationResult{}, false
+}
 
-	request, err := http.NewRequest(http.MethodHead, "https://reg-prod.ec.fhda.edu/StudentRegistrationSsb/ssb/classRegistration/classRegistration", nil)
-	if err != nil {
-		return FailedToCreateRequest
+func (s *SignupTask) ExportSchedule() error {
+	fmt.Println("Exporting schedule")
+
+	for i := range s.Registered {
+		if err := s.task.LoadMeetings(&s.Registered[i]); err != nil {
+			return err
+		}
 	}
-	request.Header.Add("accept", "*/*")
-	request.Header.Add("accept-language", "en-US,en;q=0.9")
-	request.Header.Add("user-agent", s.task.UserAgent)
 
-	resp, err := s.task.Client.Do(request)
+	format := s.task.exportFormat()
+	output, closeOutput, err := s.task.createOutput("schedule", string(format))
 	if err != nil {
-		return FailedToMakeRequest
+		return err
 	}
-	defer resp.Body.Close()
+	defer closeOutput()
 
-	body, err := io.ReadAll(resp.Body)
+	if format.Grid() {
+		err = writeWeekViews(output, format, []WeekView{{Title: "Registered schedule", Sections: s.Registered}})
+	} else {
+		err = WriteICS(output, s.Registered, s.task.Institution.Location())
+	}
 	if err != nil {
-		return FailedToReadResponseBody
+		return err
 	}
+	fmt.Println("Exported schedule")
+	return nil
+}
 
-	if len(body) > 0 {
-
+// Drop drops every CRN in CoursesToDrop.
+func (s *SignupTask) Drop() error {
+	steps := []func() error{
+		s.SaveTerm,
+		s.GetRegistrationStatus,
+		s.VisitClassRegistration,
+		s.GetRegistrationCart,
+		s.DropCourses,
+	}
+	if err := s.task.RunAuthenticated(s.task.Institution.SSO.Registration, s.RestoreTerm, steps); err != nil {
+		return err
 	}
+
+	s.task.Client.CloseIdleConnections()
 	return nil
 }
 
-func (s *SignupTask) AddCourse(CourseNumber string) error {
-	fmt.Println("Adding course")
-
-	url := fmt.Sprintf(
-		"https://reg-prod.ec.fhda.edu/StudentRegistrationSsb/ssb/classRegistration/addRegistrationItem?term=%s&courseReferenceNumber=%s&olr=false",
-		s.task.TermId, CourseNumber,
-	)
-	request, err := http.NewRequest(http.MethodGet, url, nil)
-	if err != nil {
-		return FailedToCreateRequest
+// Swap runs every swap in CoursesToSwap.
+func (s *SignupTask) Swap() error {
+	steps := []func() error{
+		s.SaveTerm,
+		s.GetRegistrationStatus,
+		s.VisitClassRegistration,
+		s.GetRegistrationCart,
+		s.SwapCourses,
+	}
+	if err := s.task.RunAuthenticated(s.task.Institution.SSO.Registration, s.RestoreTerm, steps); err != nil {
+		return err
 	}
-	request.Header.Add("accept", "*/*")
-	request.Header.Add("accept-language", "en-US,en;q=0.9")
-	request.Header.Add("user-agent", s.task.UserAgent)
 
-	resp, err := s.task.Client.Do(request)
-	if err != nil {
-		return FailedToMakeRequest
+	s.task.Client.CloseIdleConnections()
+	return nil
+}
+
+// choices returns the courses to enroll in, each as its CRNs in order of
+// preference. Without CourseChoices every CRN to add is its own course.
+func (s *SignupTask) choices() [][]string {
+	if len(s.task.CourseChoices) > 0 {
+		return s.task.CourseChoices
 	}
-	defer resp.Body.Close()
+	var choices [][]string
+	for _, crn := range s.task.CoursesToAdd {
+		if crn = strings.TrimSpace(crn); len(crn) > 0 {
+			choices = append(choices, []string{crn})
+		}
+	}
+	return choices
+}
 
-	if resp.StatusCode != 200 {
-		return UnknownHTTPResponseStatus
+// coursesToAdd returns the CRNs of the current round of EnrollChoices, or
+// every CRN to add outside of it.
+func (s *SignupTask) coursesToAdd() []string {
+	if s.round != nil {
+		return s.round
 	}
+	return s.task.CoursesToAdd
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return FailedToReadResponseBody
+// EnrollChoices submits the first choice of every course together, then the
+// next alternate of the courses that failed, until each course is registered
+// or out of alternates. With AllowWaitlist the courses left without a seat
+// then join the waitlist of their first alternate that offers one.
+func (s *SignupTask) EnrollChoices() error {
+	choices := s.choices()
+	if len(s.Chosen) != len(choices) {
+		s.Chosen = make([]string, len(choices))
+	}
+	if s.tried == nil {
+		s.tried = map[string]bool{}
 	}
+	if s.waitlistTried == nil {
+		s.waitlistTried = map[string]bool{}
+	}
+	defer func() {
+		s.round = nil
+		s.seatsOnly = false
+	}()
 
-	addCourse := AddCourse{}
-	if err := json.Unmarshal(body, &addCourse); err != nil {
-		fmt.Println(err)
-		return UnableToParseJSON
+	s.seatsOnly = true
+	if err := s.enrollRounds(choices, s.tried, RegisteredStatus); err != nil {
+		return err
 	}
-	if addCourse.Success {
-		dataModel, err := extractModel([]byte(body))
-		if err != nil {
-			fmt.Println(err)
-			return UnableToParseJSON
+	if s.task.AllowWaitlist {
+		s.seatsOnly = false
+		if err := s.enrollRounds(choices, s.waitlistTried, RegisteredStatus, WaitlistedStatus); err != nil {
+			return err
 		}
-		s.Model = dataModel
-	} else {
-		if len(addCourse.Message) > 0 {
-			fmt.Printf("Error adding course: %s\n", addCourse.Message)
-		}
-		return FailedToAddCourse
 	}
+
+	s.reportChoices(choices)
 	return nil
 }
 
-func (s *SignupTask) AddCourses() error {
-	fmt.Println("Adding courses")
-
-	for _, course := range s.task.CoursesToAdd {
-		s.AddCourse(course)
+// firstChoices reports whether the candidates of a round are the first
+// choices of their courses in the seat rounds, the plan the user asked for.
+func (s *SignupTask) firstChoices(choices [][]string, candidates map[int]string) bool {
+	if !s.seatsOnly {
+		return false
 	}
-	return nil
+	for i, crn := range candidates {
+		if choices[i][0] != crn {
+			return false
+		}
+	}
+	return true
 }
 
-func (s *SignupTask) SubmitChanges() error {
-	fmt.Println("Submitting changes")
+// enrollRounds submits the first untried alternate of every course without a
+// chosen CRN until each course has a CRN that ended up in one of statuses or
+// is out of alternates.
+func (s *SignupTask) enrollRounds(choices [][]string, tried map[string]bool, statuses ...string) error {
+	for {
+		s.round = []string{}
+		candidates := map[int]string{}
+		for i, choice := range choices {
+			if len(s.Chosen[i]) > 0 {
+				continue
+			}
+			for _, crn := range choice {
+				if !tried[crn] {
+					candidates[i] = crn
+					s.round = append(s.round, crn)
+					break
+				}
+			}
+		}
+		if len(s.round) == 0 {
+			return nil
+		}
 
-	batch := BatchUpdate{
-		Update: []map[string]interface{}{s.Model},
-	}
+		if err := s.ResolveLinkedSections(); err != nil {
+			return err
+		}
+		// Only the first choices are refused as a whole; alternates that
+		// overlap are skipped for the next one.
+		err := s.CheckSchedule()
+		if err == ScheduleConflict && !s.firstChoices(choices, candidates) {
+			s.skipConflicts(tried)
+			err = nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(s.Groups) > 0 {
+			err = s.AddCourses()
+			if err == nil {
+				err = s.SubmitChanges()
+			}
+			if err != nil && err != FailedToAddCourse {
+				return err
+			}
+		}
 
-	payloadJson, err := json.MarshalIndent(batch, "", "  ")
-	if err != nil {
-		fmt.Println(err)
-		return UnableToParseJSON
+		for i, crn := range candidates {
+			tried[crn] = true
+			result, ok := s.result(crn)
+			if !ok {
+				continue
+			}
+			for _, status := range statuses {
+				if result.Status == status {
+					s.Chosen[i] = crn
+				}
+			}
+		}
 	}
+}
 
-	request, err := http.NewRequest(http.MethodPost, "https://reg-prod.ec.fhda.edu/StudentRegistrationSsb/ssb/classRegistration/submitRegistration/batch", bytes.NewBufferString(string(payloadJson)))
-	if err != nil {
-		return FailedToCreateRequest
-	}
-	request.Header.Add("accept", "*/*")
-	request.Header.Add("accept-language", "en-US,en;q=0.9")
-	request.Header.Add("content-type", "application/json")
-	request.Header.Add("user-agent", s.task.UserAgent)
+// ValidateChoices adds every alternate of every course to the cart to surface
+// the errors Banner reports when adding, records the ones that would be
+// added, and removes them again without registering.
+func (s *SignupTask) ValidateChoices() error {
+	fmt.Println("Validating courses")
+	defer func() { s.round = nil }()
 
-	resp, err := s.task.Client.Do(request)
-	if err != nil {
-		return FailedToMakeRequest
+	// Alternates replace each other, so only the first choices are checked
+	// for conflicts.
+	s.round = []string{}
+	for _, choice := range s.choices() {
+		s.round = append(s.round, choice[0])
+	}
+	if err := s.ResolveLinkedSections(); err != nil {
+		return err
+	}
+	if err := s.CheckSchedule(); err != nil {
+		return err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return UnknownHTTPResponseStatus
+	s.round = []string{}
+	for _, choice := range s.choices() {
+		s.round = append(s.round, choice...)
+	}
+	if err := s.ResolveLinkedSections(); err != nil {
+		return err
+	}
+	if err := s.AddCourses(); err != nil && err != FailedToAddCourse {
+		return err
+	}
+	for _, model := range s.Cart {
+		s.recordResult(RegistrationResult{
+			CRN:    stringValue(model["courseReferenceNumber"]),
+			Title:  stringValue(model["courseTitle"]),
+			Status: WouldAddStatus,
+		})
+	}
+	if err := s.RemovePending(); err != nil {
+		return err
 	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return FailedToReadResponseBody
+	for _, result := range s.Results {
+		if len(result.Errors) > 0 {
+			fmt.Printf("%s %s: %s (%s)\n", result.CRN, result.Title, result.Status, strings.Join(result.Errors, "; "))
+		} else {
+			fmt.Printf("%s %s: %s\n", result.CRN, result.Title, result.Status)
+		}
 	}
-	changes := Changes{}
-	if err := json.Unmarshal(body, &changes); err != nil {
-		fmt.Println(err)
-		return UnableToParseJSON
+	return nil
+}
+
+// RemovePending removes the pending rows of the cart from the Banner
+// session. addRegistrationItem keeps the rows in the session until they are
+// submitted, and Banner only takes them out through submitRegistration/batch,
+// so this is the one batch a dry run sends. It holds nothing but Destroy
+// models of rows that were never submitted, so nothing is registered or
+// dropped.
+func (s *SignupTask) RemovePending() error {
+	if len(s.Cart) == 0 {
+		return nil
 	}
+	fmt.Printf("Removing %d pending courses\n", len(s.Cart))
 
-	for _, data := range changes.Data.Update {
-		for _, course := range s.task.CoursesToAdd {
-			if data.CourseReferenceNumber == course {
-				if len(data.CrnErrors) > 0 || data.StatusDescription == "Errors Preventing Registration" {
-					fmt.Printf("%d Errors encountered while adding %s - %s\n", len(data.CrnErrors), data.CourseReferenceNumber, data.CourseTitle)
-					for _, error := range data.CrnErrors {
-						fmt.Printf("Error received: %s\n", error.Message)
-					}
-				}
+	if _, err := s.submit(BatchUpdate{Destroy: s.Cart}); err != nil {
+		return err
+	}
+	s.Cart = nil
+	return nil
+}
 
-				if data.StatusDescription == "Registered" {
-					fmt.Printf("Successfully registered for %s - %s\n", data.CourseReferenceNumber, data.CourseTitle)
-					s.task.sendSuccessfulEnrollmentNotification(data.CourseTitle)
-				}
+func (s *SignupTask) reportChoices(choices [][]string) {
+	for i, choice := range choices {
+		if len(choice) < 2 {
+			continue
+		}
+		if len(s.Chosen[i]) == 0 {
+			fmt.Printf("Could not enroll in any of %s\n", strings.Join(choice, ", "))
+			continue
+		}
+		for rank, crn := range choice {
+			if crn == s.Chosen[i] {
+				fmt.Printf("Enrolled in %s, alternate %d of %s\n", crn, rank+1, strings.Join(choice, ", "))
 			}
 		}
 	}
-	return nil
 }
 
-func (s *SignupTask) Run() error {
+func (s *SignupTask) RestoreTerm() error {
+	if err := s.SaveTerm(); err != nil {
+		return err
+	}
+	return s.GetRegistrationStatus()
+}
 
+func (s *SignupTask) Run() error {
 	steps := []func() error{
-		s.VisitHomepage,
-		s.Login,
-		s.SubmitCommonAuth,
-		s.SubmitSSOManager,
-		s.RegisterPostSignIn,
-		s.SubmitSamIsso,
-		s.SubmitSSBSp,
 		s.SaveTerm,
 		s.GetRegistrationStatus,
 		s.VisitClassRegistration,
-		s.AddCourses,
-		s.SubmitChanges,
+		s.EnrollChoices,
+	}
+	if s.task.DryRun {
+		steps[len(steps)-1] = s.ValidateChoices
+	} else if format := s.task.exportFormat(); format == FormatICS || format.Grid() {
+		steps = append(steps, s.ExportSchedule)
 	}
 
-	for _, step := range steps {
-		if err := Retry(s.task.RetryAmount, s.task.RetryDuration, step); err != nil {
-			return MaximumAttemptsRetry
-		}
+	if err := s.task.RunAuthenticated(s.task.Institution.SSO.Registration, s.RestoreTerm, steps); err != nil {
+		return err
 	}
 
 	s.task.Client.CloseIdleConnections()
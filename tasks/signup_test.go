@@ -0,0 +1,388 @@
+package tasks
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/veil/mock"
+)
+
+func TestSignupTaskRegisters(t *testing.T) {
+	server := newMockServer(t)
+	task := newMockTask(t, server)
+	task.CoursesToAdd = []string{"30001"}
+
+	if err := NewSignupTask(task).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if !server.Registered("30001") {
+		t.Error("30001 was not registered")
+	}
+	if server.Logins() != 1 {
+		t.Errorf("logins = %d, want 1", server.Logins())
+	}
+}
+
+func TestSignupTaskFullSection(t *testing.T) {
+	server := newMockServer(t)
+	task := newMockTask(t, server)
+	task.CoursesToAdd = []string{"30002"}
+
+	if err := NewSignupTask(task).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if server.Registered("30002") {
+		t.Error("full section 30002 was registered")
+	}
+	if server.WaitlistPosition("30002") != 0 {
+		t.Error("joined the waitlist without AllowWaitlist")
+	}
+}
+
+func TestSignupTaskJoinsWaitlist(t *testing.T) {
+	server := newMockServer(t)
+	task := newMockTask(t, server)
+	task.CoursesToAdd = []string{"30002"}
+	task.AllowWaitlist = true
+	task.WebhookURL = server.WebhookURL()
+
+	signup := NewSignupTask(task)
+	if err := signup.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if server.Registered("30002") {
+		t.Error("full section 30002 was registered")
+	}
+	if position := server.WaitlistPosition("30002"); position != 4 {
+		t.Fatalf("waitlist position = %d, want 4", position)
+	}
+	result, _ := signup.result("30002")
+	if result.Status != WaitlistedStatus || result.WaitPosition != 4 {
+		t.Errorf("result = %+v", result)
+	}
+	if len(signup.Registered) != 0 {
+		t.Errorf("registered = %+v", signup.Registered)
+	}
+	webhooks := server.Webhooks()
+	if len(webhooks) != 1 || !strings.Contains(webhooks[0], "Waitlisted") || !strings.Contains(webhooks[0], "position 4") {
+		t.Errorf("webhooks = %v", webhooks)
+	}
+}
+
+func TestIsWaitlistError(t *testing.T) {
+	for message, want := range map[string]bool{
+		"Closed - 3 Waitlisted":             true,
+		"Open - Reserved for Waitlist":      true,
+		"Waitlist Full":                     false,
+		"Waitlist closed":                   false,
+		"Student not eligible for waitlist": false,
+		"Closed Section":                    false,
+	} {
+		if got := isWaitlistError(message); got != want {
+			t.Errorf("isWaitlistError(%q) = %v, want %v", message, got, want)
+		}
+	}
+}
+
+func TestSignupTaskBadPassword(t *testing.T) {
+	server := newMockServer(t)
+	task := newMockTask(t, server)
+	task.Password = "wrong"
+	task.CoursesToAdd = []string{"30001"}
+
+	if err := NewSignupTask(task).Run(); err != MaximumAttemptsRetry {
+		t.Fatalf("err = %v, want %v", err, MaximumAttemptsRetry)
+	}
+	if server.Logins() != 0 {
+		t.Errorf("logins = %d, want 0", server.Logins())
+	}
+	if task.LoginAttempts != task.RetryAmount {
+		t.Errorf("login attempts = %d, want %d", task.LoginAttempts, task.RetryAmount)
+	}
+}
+
+func TestSignupTaskNotEligible(t *testing.T) {
+	server := newMockServer(t)
+	server.EligibilityFailures = []string{"You have a hold which prevents registration."}
+	task := newMockTask(t, server)
+	task.CoursesToAdd = []string{"30001"}
+
+	if err := NewSignupTask(task).Run(); err != MaximumAttemptsRetry {
+		t.Fatalf("err = %v, want %v", err, MaximumAttemptsRetry)
+	}
+	if server.Registered("30001") {
+		t.Error("registered despite eligibility failure")
+	}
+}
+
+func TestSignupTaskRetriesFailedAdd(t *testing.T) {
+	server := newMockServer(t)
+	server.AddFailures = map[string]int{"30001": 1}
+	task := newMockTask(t, server)
+	task.CoursesToAdd = []string{"30001", "30003"}
+
+	if err := NewSignupTask(task).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if !server.Registered("30001") || !server.Registered("30003") {
+		t.Error("a server error while adding lost a course")
+	}
+}
+
+func TestSignupTaskReusesSavedSession(t *testing.T) {
+	server := newMockServer(t)
+	store := NewSessionStore(t.TempDir())
+
+	first := newMockTask(t, server)
+	first.SessionStore = store
+	first.CoursesToAdd = []string{"30001"}
+	if err := NewSignupTask(first).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	second := newMockTask(t, server)
+	second.SessionStore = store
+	second.CoursesToAdd = []string{"30003"}
+	if err := NewSignupTask(second).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !server.Registered("30003") {
+		t.Error("30003 was not registered with the saved session")
+	}
+	if server.Logins() != 1 {
+		t.Errorf("logins = %d, want 1", server.Logins())
+	}
+}
+
+func TestSignupTaskReauthenticatesWhenSessionExpires(t *testing.T) {
+	server := newMockServer(t)
+	expired := false
+	server.BeforeRequest = func(r *http.Request) {
+		if !expired && strings.HasSuffix(r.URL.Path, "/addRegistrationItem") {
+			expired = true
+			server.ExpireSessions(mock.RoleRegistration, mock.RoleSSOManager)
+		}
+	}
+	task := newMockTask(t, server)
+	task.RetryAmount = 1
+	task.CoursesToAdd = []string{"30001"}
+
+	if err := NewSignupTask(task).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if !server.Registered("30001") {
+		t.Error("30001 was not registered after re-authenticating")
+	}
+	if server.Logins() != 2 {
+		t.Errorf("logins = %d, want 2", server.Logins())
+	}
+}
+
+func TestSignupTaskSubmitsWholeCart(t *testing.T) {
+	server := newMockServer(t)
+	submits := 0
+	server.BeforeRequest = func(r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/submitRegistration/batch") {
+			submits++
+		}
+	}
+	task := newMockTask(t, server)
+	task.CoursesToAdd = []string{"30001", "30002", "99999", "30003"}
+
+	signup := NewSignupTask(task)
+	if err := signup.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if submits != 1 {
+		t.Errorf("submits = %d, want 1", submits)
+	}
+	if !server.Registered("30001") || !server.Registered("30003") {
+		t.Error("30001 and 30003 were not both registered")
+	}
+
+	statuses := map[string]RegistrationResult{}
+	for _, result := range signup.Results {
+		statuses[result.CRN] = result
+	}
+	want := map[string]string{
+		"30001": RegisteredStatus,
+		"30002": "Errors Preventing Registration",
+		"99999": NotAddedStatus,
+		"30003": RegisteredStatus,
+	}
+	for crn, status := range want {
+		if statuses[crn].Status != status {
+			t.Errorf("%s status = %q, want %q", crn, statuses[crn].Status, status)
+		}
+	}
+	if errors := statuses["30002"].Errors; len(errors) != 1 || !strings.HasPrefix(errors[0], "Closed") {
+		t.Errorf("30002 errors = %v", errors)
+	}
+	if errors := statuses["99999"].Errors; len(errors) != 1 {
+		t.Errorf("99999 errors = %v", errors)
+	}
+}
+
+func TestSignupTaskDrops(t *testing.T) {
+	server := newMockServer(t)
+	server.Register("30001")
+	task := newMockTask(t, server)
+	task.CoursesToDrop = []string{"30001", "30003"}
+
+	signup := NewSignupTask(task)
+	if err := signup.Drop(); err != nil {
+		t.Fatal(err)
+	}
+	if server.Registered("30001") {
+		t.Error("30001 is still registered")
+	}
+	if result, _ := signup.result("30001"); result.Status != DroppedStatus {
+		t.Errorf("30001 status = %q, want %q", result.Status, DroppedStatus)
+	}
+	if result, _ := signup.result("30003"); result.Status != NotRegisteredStatus {
+		t.Errorf("30003 status = %q, want %q", result.Status, NotRegisteredStatus)
+	}
+}
+
+func TestSignupTaskSwaps(t *testing.T) {
+	server := newMockServer(t)
+	server.Register("30001")
+	task := newMockTask(t, server)
+	task.CoursesToSwap = []Swap{{Drop: "30001", Add: "30003"}}
+
+	if err := NewSignupTask(task).Swap(); err != nil {
+		t.Fatal(err)
+	}
+	if server.Registered("30001") || !server.Registered("30003") {
+		t.Errorf("registered 30001 = %v, 30003 = %v, want swapped", server.Registered("30001"), server.Registered("30003"))
+	}
+}
+
+func TestSignupTaskSwapKeepsSeatWhenAddFails(t *testing.T) {
+	server := newMockServer(t)
+	server.Register("30001")
+	task := newMockTask(t, server)
+	task.CoursesToSwap = []Swap{{Drop: "30001", Add: "30002"}}
+
+	signup := NewSignupTask(task)
+	if err := signup.Swap(); err != nil {
+		t.Fatal(err)
+	}
+	if !server.Registered("30001") {
+		t.Error("lost 30001 when adding full section 30002 failed")
+	}
+	if result, _ := signup.result("30002"); result.Status != "Errors Preventing Registration" {
+		t.Errorf("30002 status = %q", result.Status)
+	}
+	if result, _ := signup.result("30001"); result.Status == DroppedStatus || len(result.Errors) == 0 {
+		t.Errorf("30001 result = %+v, want drop refused", result)
+	}
+}
+
+func TestParseSwaps(t *testing.T) {
+	swaps, err := ParseSwaps("30001:30003, 30002:30004")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(swaps) != 2 || swaps[1] != (Swap{Drop: "30002", Add: "30004"}) {
+		t.Errorf("swaps = %+v", swaps)
+	}
+	if _, err := ParseSwaps("30001"); err != InvalidSwap {
+		t.Errorf("err = %v, want %v", err, InvalidSwap)
+	}
+}
+
+func TestSignupTaskTriesAlternates(t *testing.T) {
+	server := newMockServer(t)
+	submits := 0
+	server.BeforeRequest = func(r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/submitRegistration/batch") {
+			submits++
+		}
+	}
+	task := newMockTask(t, server)
+	task.CourseChoices = ParseCourseChoices("30002|30001|30004, 30003")
+
+	signup := NewSignupTask(task)
+	if err := signup.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(signup.Chosen) != 2 || signup.Chosen[0] != "30001" || signup.Chosen[1] != "30003" {
+		t.Errorf("chosen = %v, want [30001 30003]", signup.Chosen)
+	}
+	if !server.Registered("30001") || !server.Registered("30003") || server.Registered("30002") {
+		t.Error("did not register the second choice 30001 and 30003")
+	}
+	if submits != 2 {
+		t.Errorf("submits = %d, want 2", submits)
+	}
+	if _, tried := signup.result("30004"); tried {
+		t.Error("tried 30004 after 30001 was registered")
+	}
+}
+
+func TestSignupTaskPrefersSeatToWaitlist(t *testing.T) {
+	server := newMockServer(t)
+	task := newMockTask(t, server)
+	task.AllowWaitlist = true
+	task.CourseChoices = ParseCourseChoices("30002|30001")
+
+	signup := NewSignupTask(task)
+	if err := signup.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(signup.Chosen) != 1 || signup.Chosen[0] != "30001" {
+		t.Errorf("chosen = %v, want [30001]", signup.Chosen)
+	}
+	if !server.Registered("30001") {
+		t.Error("did not register the open alternate 30001")
+	}
+	if server.WaitlistPosition("30002") != 0 {
+		t.Error("joined the waitlist of 30002 while 30001 had a seat")
+	}
+}
+
+func TestSignupTaskDryRun(t *testing.T) {
+	server := newMockServer(t)
+	server.RegistrationOpensAt = time.Now().Add(48 * time.Hour)
+	var batches []BatchUpdate
+	server.BeforeRequest = func(r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/submitRegistration/batch") {
+			body, _ := io.ReadAll(r.Body)
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			batch := BatchUpdate{}
+			json.Unmarshal(body, &batch)
+			batches = append(batches, batch)
+		}
+	}
+	task := newMockTask(t, server)
+	task.DryRun = true
+	task.CourseChoices = ParseCourseChoices("30001|30003, 99999")
+
+	signup := NewSignupTask(task)
+	if err := signup.Run(); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"30001": WouldAddStatus, "30003": WouldAddStatus, "99999": NotAddedStatus}
+	for crn, status := range want {
+		if result, _ := signup.result(crn); result.Status != status {
+			t.Errorf("%s status = %q, want %q", crn, result.Status, status)
+		}
+		if server.Registered(crn) || server.Pending(crn) {
+			t.Errorf("%s was left registered or pending", crn)
+		}
+	}
+	// Banner only removes pending rows through a batch of Destroy models.
+	if len(batches) != 1 || len(batches[0].Create) > 0 || len(batches[0].Update) > 0 || len(batches[0].Destroy) != 2 {
+		t.Errorf("batches = %+v, want only the removal of pending courses", batches)
+	}
+	if _, err := signup.submit(BatchUpdate{Update: []map[string]interface{}{{"courseReferenceNumber": "30001"}}}); err != DryRunSubmit {
+		t.Errorf("err = %v, want DryRunSubmit", err)
+	}
+}
@@ -0,0 +1,218 @@
+package tasks
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const snapshotSchema = `
+CREATE TABLE IF NOT EXISTS snapshots (
+	id                 INTEGER PRIMARY KEY,
+	recorded_at        INTEGER NOT NULL,
+	term               TEXT NOT NULL,
+	crn                TEXT NOT NULL,
+	subject            TEXT NOT NULL,
+	course_number      TEXT NOT NULL,
+	sequence_number    TEXT NOT NULL,
+	title              TEXT NOT NULL,
+	maximum_enrollment INTEGER NOT NULL,
+	enrollment         INTEGER NOT NULL,
+	seats_available    INTEGER NOT NULL,
+	wait_capacity      INTEGER NOT NULL,
+	wait_count         INTEGER NOT NULL,
+	wait_available     INTEGER NOT NULL,
+	section            TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS snapshots_section ON snapshots (term, crn, recorded_at);
+CREATE INDEX IF NOT EXISTS snapshots_recorded_at ON snapshots (recorded_at);
+`
+
+// Snapshot is the enrollment of a section at the time a search saw it.
+type Snapshot struct {
+	RecordedAt        time.Time `json:"recordedAt"`
+	Term              string    `json:"term"`
+	CRN               string    `json:"crn"`
+	Subject           string    `json:"subject"`
+	CourseNumber      string    `json:"courseNumber"`
+	SequenceNumber    string    `json:"sequenceNumber"`
+	Title             string    `json:"title"`
+	MaximumEnrollment int       `json:"maximumEnrollment"`
+	Enrollment        int       `json:"enrollment"`
+	SeatsAvailable    int       `json:"seatsAvailable"`
+	WaitCapacity      int       `json:"waitCapacity"`
+	WaitCount         int       `json:"waitCount"`
+	WaitAvailable     int       `json:"waitAvailable"`
+}
+
+func (s Snapshot) FillRate() float64 {
+	if s.MaximumEnrollment <= 0 {
+		return 0
+	}
+	return float64(s.Enrollment) / float64(s.MaximumEnrollment)
+}
+
+// Reopening is a section that had no open seats in one snapshot and open
+// seats in the next.
+type Reopening struct {
+	Term           string    `json:"term"`
+	CRN            string    `json:"crn"`
+	Subject        string    `json:"subject"`
+	CourseNumber   string    `json:"courseNumber"`
+	SequenceNumber string    `json:"sequenceNumber"`
+	Title          string    `json:"title"`
+	FullAt         time.Time `json:"fullAt"`
+	OpenAt         time.Time `json:"openAt"`
+	SeatsAvailable int       `json:"seatsAvailable"`
+}
+
+type SnapshotStore struct {
+	db *sql.DB
+}
+
+func OpenSnapshotStore(path string) (*SnapshotStore, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, FailedOpeningSnapshotStore
+		}
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		fmt.Println(err)
+		return nil, FailedOpeningSnapshotStore
+	}
+	// SQLite allows a single writer; share one connection between goroutines.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(snapshotSchema); err != nil {
+		fmt.Println(err)
+		db.Close()
+		return nil, FailedOpeningSnapshotStore
+	}
+	return &SnapshotStore{db: db}, nil
+}
+
+func (store *SnapshotStore) Close() error {
+	return store.db.Close()
+}
+
+func (store *SnapshotStore) Record(recordedAt time.Time, sections []Section) error {
+	tx, err := store.db.Begin()
+	if err != nil {
+		return FailedSavingSnapshot
+	}
+	defer tx.Rollback()
+
+	statement, err := tx.Prepare(`INSERT INTO snapshots (
+		recorded_at, term, crn, subject, course_number, sequence_number, title, maximum_enrollment,
+		enrollment, seats_available, wait_capacity, wait_count, wait_available, section
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		fmt.Println(err)
+		return FailedSavingSnapshot
+	}
+	defer statement.Close()
+
+	for _, section := range sections {
+		data, err := json.Marshal(section)
+		if err != nil {
+			return UnableToParseJSON
+		}
+		_, err = statement.Exec(
+			recordedAt.UnixMilli(), section.Term, section.CRN, section.Subject, section.CourseNumber,
+			section.SequenceNumber, section.Title, section.MaximumEnrollment, section.Enrollment,
+			section.SeatsAvailable, section.WaitCapacity, section.WaitCount, section.WaitAvailable, string(data),
+		)
+		if err != nil {
+			fmt.Println(err)
+			return FailedSavingSnapshot
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return FailedSavingSnapshot
+	}
+	return nil
+}
+
+// History returns the snapshots of a section recorded since the given time,
+// oldest first.
+func (store *SnapshotStore) History(term string, crn string, since time.Time) ([]Snapshot, error) {
+	rows, err := store.db.Query(`SELECT
+		recorded_at, term, crn, subject, course_number, sequence_number, title, maximum_enrollment,
+		enrollment, seats_available, wait_capacity, wait_count, wait_available
+	FROM snapshots
+	WHERE term = ? AND crn = ? AND recorded_at >= ?
+	ORDER BY recorded_at`, term, crn, since.UnixMilli())
+	if err != nil {
+		fmt.Println(err)
+		return nil, FailedQueryingSnapshots
+	}
+	defer rows.Close()
+
+	var snapshots []Snapshot
+	for rows.Next() {
+		var snapshot Snapshot
+		var recordedAt int64
+		err := rows.Scan(
+			&recordedAt, &snapshot.Term, &snapshot.CRN, &snapshot.Subject, &snapshot.CourseNumber,
+			&snapshot.SequenceNumber, &snapshot.Title, &snapshot.MaximumEnrollment, &snapshot.Enrollment,
+			&snapshot.SeatsAvailable, &snapshot.WaitCapacity, &snapshot.WaitCount, &snapshot.WaitAvailable,
+		)
+		if err != nil {
+			fmt.Println(err)
+			return nil, FailedQueryingSnapshots
+		}
+		snapshot.RecordedAt = time.UnixMilli(recordedAt)
+		snapshots = append(snapshots, snapshot)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, FailedQueryingSnapshots
+	}
+	return snapshots, nil
+}
+
+// Reopened returns the sections that went from full to open since the given
+// time, comparing each snapshot with the previous one of the same section.
+func (store *SnapshotStore) Reopened(since time.Time) ([]Reopening, error) {
+	rows, err := store.db.Query(`SELECT term, crn, subject, course_number, sequence_number, title, previous_at, recorded_at, seats_available
+	FROM (
+		SELECT *,
+			LAG(seats_available) OVER section_history AS previous_seats,
+			LAG(recorded_at) OVER section_history AS previous_at
+		FROM snapshots
+		WINDOW section_history AS (PARTITION BY term, crn ORDER BY recorded_at)
+	)
+	WHERE previous_seats <= 0 AND seats_available > 0 AND recorded_at >= ?
+	ORDER BY recorded_at, term, crn`, since.UnixMilli())
+	if err != nil {
+		fmt.Println(err)
+		return nil, FailedQueryingSnapshots
+	}
+	defer rows.Close()
+
+	var reopenings []Reopening
+	for rows.Next() {
+		var reopening Reopening
+		var fullAt, openAt int64
+		err := rows.Scan(
+			&reopening.Term, &reopening.CRN, &reopening.Subject, &reopening.CourseNumber,
+			&reopening.SequenceNumber, &reopening.Title, &fullAt, &openAt, &reopening.SeatsAvailable,
+		)
+		if err != nil {
+			fmt.Println(err)
+			return nil, FailedQueryingSnapshots
+		}
+		reopening.FullAt = time.UnixMilli(fullAt)
+		reopening.OpenAt = time.UnixMilli(openAt)
+		reopenings = append(reopenings, reopening)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, FailedQueryingSnapshots
+	}
+	return reopenings, nil
+}
@@ -0,0 +1,87 @@
+package tasks
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestSnapshotStore(t *testing.T) *SnapshotStore {
+	t.Helper()
+	store, err := OpenSnapshotStore(filepath.Join(t.TempDir(), "snapshots", "veil.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestSnapshotStoreHistoryAndReopened(t *testing.T) {
+	store := openTestSnapshotStore(t)
+	start := time.Date(2024, time.January, 2, 9, 0, 0, 0, time.UTC)
+	section := func(crn string, enrolled int) Section {
+		return Section{Term: "202432", CRN: crn, Subject: "PHYS", CourseNumber: "4A", Title: "PHYSICS", MaximumEnrollment: 40, Enrollment: enrolled, SeatsAvailable: 40 - enrolled}
+	}
+
+	runs := [][]Section{
+		{section("30001", 30), section("30002", 40)},
+		{section("30001", 35), section("30002", 40)},
+		{section("30001", 40), section("30002", 38)},
+	}
+	for i, sections := range runs {
+		if err := store.Record(start.Add(time.Duration(i)*time.Hour), sections); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	history, err := store.History("202432", "30001", start.Add(30*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].Enrollment != 35 || history[1].FillRate() != 1 {
+		t.Errorf("unexpected history %+v", history)
+	}
+	if !history[1].RecordedAt.Equal(start.Add(2 * time.Hour)) {
+		t.Errorf("recorded at %v", history[1].RecordedAt)
+	}
+
+	reopened, err := store.Reopened(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reopened) != 1 || reopened[0].CRN != "30002" || reopened[0].SeatsAvailable != 2 || !reopened[0].FullAt.Equal(start.Add(time.Hour)) {
+		t.Errorf("unexpected reopenings %+v", reopened)
+	}
+}
+
+func TestSearchTaskPersistsSnapshots(t *testing.T) {
+	server := newMockServer(t)
+	task := newMockTask(t, server)
+	chdirTemp(t)
+	task.Snapshots = openTestSnapshotStore(t)
+
+	if err := NewSearchTask(task).Run(); err != nil {
+		t.Fatal(err)
+	}
+	server.Sections[1].Enrolled = 39
+	if err := NewSearchTask(task).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := task.Snapshots.History("202432", "30002", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].SeatsAvailable != 0 || history[1].SeatsAvailable != 1 {
+		t.Errorf("unexpected history %+v", history)
+	}
+
+	task.CoursesToAdd = []string{"30002"}
+	historyTask := NewHistoryTask(task)
+	if err := historyTask.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(historyTask.History["30002"]) != 2 || len(historyTask.Reopened) != 1 {
+		t.Errorf("unexpected history task results %+v %+v", historyTask.History, historyTask.Reopened)
+	}
+}
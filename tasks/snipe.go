@@ -0,0 +1,166 @@
+package tasks
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultKeepAlive is how often an idle snipe session touches the
+// registration page so Banner does not time it out.
+const DefaultKeepAlive = 5 * time.Minute
+
+type SnipeTask struct {
+	task      *Task
+	signup    *SignupTask
+	watch     *WatchTask
+	enrolled  map[string]bool
+	attempted map[string]Section
+	lastTouch time.Time
+	// MaxPolls stops Snipe after that many polls, 0 polls until every CRN is
+	// enrolled.
+	MaxPolls int
+}
+
+// Enroll adds crn and submits it straight away. It assumes the term is saved
+// and the session is authenticated, so none of the signup setup is repeated.
+func (s *SnipeTask) Enroll(crn string) error {
+	start := time.Now()
+	s.signup.Cart = nil
+	if err := s.signup.AddCourse(crn); err != nil {
+		return err
+	}
+	if err := s.signup.SubmitChanges(); err != nil {
+		return err
+	}
+	result, _ := s.signup.result(crn)
+	if result.Status != RegisteredStatus && result.Status != WaitlistedStatus {
+		return FailedToAddCourse
+	}
+	fmt.Printf("Enrolled in %s after %s\n", crn, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// shouldEnroll reports whether section has a seat, or a waitlist seat when
+// waitlisting is allowed, that has not already been tried.
+func (s *SnipeTask) shouldEnroll(section Section) bool {
+	if s.enrolled[section.CRN] {
+		return false
+	}
+	open := section.SeatsAvailable > 0 || (s.task.AllowWaitlist && section.WaitAvailable > 0)
+	if !open {
+		return false
+	}
+	attempt, attempted := s.attempted[section.CRN]
+	return !attempted || attempt.SeatsAvailable != section.SeatsAvailable || attempt.WaitAvailable != section.WaitAvailable
+}
+
+func (s *SnipeTask) keepAlive() error {
+	keepAlive := s.task.KeepAlive
+	if keepAlive <= 0 {
+		keepAlive = DefaultKeepAlive
+	}
+	if time.Since(s.lastTouch) < keepAlive {
+		return nil
+	}
+	s.lastTouch = time.Now()
+	return s.signup.VisitClassRegistration()
+}
+
+func (s *SnipeTask) remaining() []string {
+	var remaining []string
+	for _, crn := range s.watch.crns() {
+		if !s.enrolled[crn] {
+			remaining = append(remaining, crn)
+		}
+	}
+	return remaining
+}
+
+func (s *SnipeTask) Snipe() error {
+	s.lastTouch = time.Now()
+	for polls := 1; len(s.remaining()) > 0; polls++ {
+		if err := s.keepAlive(); err == SessionExpired {
+			return err
+		}
+
+		if _, err := s.watch.Poll(); err != nil {
+			s.watch.pollFailed(err)
+		} else {
+			s.watch.failures = 0
+		}
+
+		for _, crn := range s.remaining() {
+			section, ok := s.watch.last[crn]
+			if !ok || !s.shouldEnroll(section) {
+				continue
+			}
+			s.attempted[crn] = section
+			err := s.Enroll(crn)
+			if err == SessionExpired {
+				delete(s.attempted, crn)
+				return err
+			}
+			if err != nil {
+				fmt.Printf("Failed to enroll in %s: %s\n", crn, err)
+				message := fmt.Sprintf("%s %s %s-%s %s\n%s", crn, section.Subject, section.CourseNumber, section.SequenceNumber, section.Title, err)
+				if err := s.task.sendNotification("Enrollment Failed", message); err != nil {
+					fmt.Println(err)
+				}
+				continue
+			}
+			s.enrolled[crn] = true
+		}
+
+		if s.MaxPolls > 0 && polls >= s.MaxPolls {
+			break
+		}
+		if len(s.remaining()) > 0 {
+			time.Sleep(s.watch.nextPoll())
+		}
+	}
+	return nil
+}
+
+func (s *SnipeTask) Run() error {
+	if len(s.watch.crns()) == 0 {
+		return NoCRNsToWatch
+	}
+	fmt.Printf("Sniping %s\n", strings.Join(s.watch.crns(), ", "))
+
+	steps := []func() error{
+		s.signup.SaveTerm,
+		s.signup.GetRegistrationStatus,
+		s.signup.VisitClassRegistration,
+		s.Snipe,
+	}
+
+	if err := s.task.RunAuthenticated(s.task.Institution.SSO.Registration, s.signup.RestoreTerm, steps); err != nil {
+		return err
+	}
+
+	s.task.Client.CloseIdleConnections()
+	return nil
+}
+
+func NewSnipeTask(task *Task) *SnipeTask {
+	// Polling runs class searches, which keep their own state in the Banner
+	// session, so it uses a separate client when one is configured. Without
+	// one, failed polls must not reset the registration session.
+	watchTask := *task
+	watchTask.auth = nil
+	watchTask.WatchCRNs = task.CoursesToAdd
+	if task.SearchClient != nil {
+		watchTask.Client = task.SearchClient
+	}
+	watch := NewWatchTask(&watchTask)
+	watch.sharedSession = task.SearchClient == nil
+
+	return &SnipeTask{
+		task:      task,
+		signup:    NewSignupTask(task),
+		watch:     watch,
+		enrolled:  map[string]bool{},
+		attempted: map[string]Section{},
+	}
+}
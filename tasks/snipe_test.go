@@ -0,0 +1,84 @@
+package tasks
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	tls_client "github.com/bogdanfinn/tls-client"
+	"github.com/bogdanfinn/tls-client/profiles"
+)
+
+func TestSnipeTaskEnrollsWhenSeatsOpen(t *testing.T) {
+	server := newMockServer(t)
+	task := newMockTask(t, server)
+	task.CoursesToAdd = []string{"30002"}
+	task.WebhookURL = server.WebhookURL()
+	task.PollInterval = time.Millisecond
+
+	searchClient, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(),
+		tls_client.WithClientProfile(profiles.Chrome_117),
+		tls_client.WithCookieJar(tls_client.NewCookieJar()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task.SearchClient = searchClient
+
+	searches := 0
+	server.BeforeRequest = func(r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/searchResults/searchResults") {
+			searches++
+			if searches == 2 {
+				server.Sections[1].Enrolled = 39
+			}
+		}
+	}
+
+	snipe := NewSnipeTask(task)
+	snipe.MaxPolls = 5
+	if err := snipe.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if !server.Registered("30002") {
+		t.Fatal("30002 was not registered")
+	}
+	if searches != 2 {
+		t.Errorf("searched %d times, want to stop after enrolling", searches)
+	}
+	webhooks := server.Webhooks()
+	if len(webhooks) != 1 || !strings.Contains(webhooks[0], "Successful Enrollment") {
+		t.Errorf("webhooks = %v", webhooks)
+	}
+}
+
+func TestSnipeTaskSkipsWaitlistUnlessAllowed(t *testing.T) {
+	snipe := NewSnipeTask(&Task{CoursesToAdd: []string{"30002"}})
+	section := Section{CRN: "30002", WaitAvailable: 3}
+	if snipe.shouldEnroll(section) {
+		t.Error("enrolled in a waitlist without AllowWaitlist")
+	}
+	snipe.task.AllowWaitlist = true
+	if !snipe.shouldEnroll(section) {
+		t.Error("did not enroll in an open waitlist")
+	}
+	snipe.attempted["30002"] = section
+	if snipe.shouldEnroll(section) {
+		t.Error("retried an unchanged section")
+	}
+}
+
+func TestSnipeTaskKeepsSharedRegistrationSession(t *testing.T) {
+	server := newMockServer(t)
+	task := newMockTask(t, server)
+	snipe := NewSnipeTask(task)
+
+	jar := task.Client.GetCookieJar()
+	for i := 0; i < MaxPollFailures; i++ {
+		snipe.watch.pollFailed(FailedToMakeRequest)
+	}
+	if task.Client.GetCookieJar() != jar {
+		t.Error("failed polls reset the registration session")
+	}
+}
@@ -6,44 +6,77 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	http "github.com/bogdanfinn/fhttp"
 	tls_client "github.com/bogdanfinn/tls-client"
 )
 
+const MaxReauthentications = 3
+
 type Task struct {
-	Subject       string
-	Term          string
-	TermId        string
-	CoursesToAdd  []string
-	Client        tls_client.HttpClient
-	UserAgent     string
-	RetryDuration time.Duration
-	RetryAmount   int
-	Username      string
-	Password      string
-	WebhookURL    string
-	LoginAttempts int
+	Subject           string
+	Subjects          []string
+	Criteria          SearchCriteria
+	Term              string
+	TermId            string
+	TermIds           []string
+	SearchConcurrency int
+	CoursesToAdd      []string
+	CourseChoices     [][]string
+	DryRun            bool
+	AllowConflicts    bool
+	RegistrationLead  time.Duration
+	RegistrationPoll  time.Duration
+	CoursesToDrop     []string
+	CoursesToSwap     []Swap
+	DesiredCourses    []DesiredCourse
+	Preferences       SchedulePreferences
+	ScheduleCount     int
+	Client            tls_client.HttpClient
+	UserAgent         string
+	RetryDuration     time.Duration
+	RetryAmount       int
+	PageSize          int
+	PageConcurrency   int
+	Username          string
+	Password          string
+	WebhookURL        string
+	LoginAttempts     int
+	OutputFormat      ExportFormat
+	Output            string
+	Stdout            io.Writer
+	SessionStore      *SessionStore
+	Snapshots         *SnapshotStore
+	HistorySince      time.Duration
+	WatchCRNs         []string
+	PollInterval      time.Duration
+	PollJitter        time.Duration
+	AllowWaitlist     bool
+	KeepAlive         time.Duration
+	SearchClient      tls_client.HttpClient
+	Institution       *Institution
+	auth              *Authenticator
 }
 
-func BuildTermId(year int, campus string, quarter string) (string, error) {
-	campusCode, ok1 := CampusCodes[campus]
-	if !ok1 {
-		return "", InvalidCampus
-	}
-	quarterCode, ok2 := QuarterCodes[quarter]
-	if !ok2 {
-		return "", InvalidQuarter
+func (task *Task) Authenticator() *Authenticator {
+	if task.auth == nil {
+		task.auth = NewAuthenticator(task)
 	}
-	return fmt.Sprintf("%d%d%d", year, quarterCode, campusCode), nil
+	return task.auth
 }
 
 func (task *Task) SearchTerm() (string, error) {
+	return task.FindTerm(task.TermId)
+}
+
+func (task *Task) FindTerm(termId string) (string, error) {
 	fmt.Println("Searching for term")
 
-	request, err := http.NewRequest(http.MethodGet, "https://reg-prod.ec.fhda.edu/StudentRegistrationSsb/ssb/classSearch/getTerms?searchTerm=&offset=1&max=10", nil)
+	request, err := http.NewRequest(http.MethodGet, task.Institution.Registration("/ssb/classSearch/getTerms?searchTerm=&offset=1&max=10"), nil)
 	if err != nil {
 		return "", FailedToCreateRequest
 	}
@@ -74,7 +107,7 @@ func (task *Task) SearchTerm() (string, error) {
 
 	var termDesc string
 	for _, term := range terms {
-		if task.TermId == term.Code {
+		if termId == term.Code {
 			termDesc = term.Description
 		}
 	}
@@ -85,6 +118,44 @@ func (task *Task) SearchTerm() (string, error) {
 	}
 }
 
+func (task *Task) GetMeetingTimes(termId string, crn string) ([]MeetingFaculty, error) {
+	url := task.Institution.Registration(fmt.Sprintf(
+		"/ssb/searchResults/getFacultyMeetingTimes?term=%s&courseReferenceNumber=%s",
+		termId, crn,
+	))
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, FailedToCreateRequest
+	}
+	request.Header.Add("accept", "application/json")
+	request.Header.Add("accept-language", "en-US,en;q=0.9")
+	request.Header.Add("user-agent", task.UserAgent)
+
+	resp, err := task.Client.Do(request)
+	if err != nil {
+		return nil, FailedToMakeRequest
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, FailedToReadResponseBody
+	}
+	if err := task.checkSession(resp, body); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		return nil, FailedGettingMeetingTimes
+	}
+
+	meetingTimes := MeetingTimes{}
+	if err := json.Unmarshal(body, &meetingTimes); err != nil {
+		fmt.Println(err)
+		return nil, UnableToParseJSON
+	}
+	return meetingTimes.Fmt, nil
+}
+
 func Convert24HourTimeTo12HourFormat(input string) string {
 	if len(input) != 4 {
 		return ""
@@ -135,14 +206,89 @@ func Retry(attempts int, sleep time.Duration, f func() error) (err error) {
 		} else if err == NotEligibleToRegister {
 			fmt.Println(err)
 			break
+		} else if err == ScheduleConflict || err == InvalidExportFormat {
+			return err
+		} else if err == NoSchedulesFound {
+			fmt.Println(err)
+			break
 		} else if err == FailedSubmittingChangesCRNErrors {
 			fmt.Println(err)
 			break
+		} else if err == SessionExpired {
+			return err
 		}
 	}
 	return MaximumAttemptsRetry
 }
 
+func (task *Task) RunAuthenticated(providers []ServiceProvider, restore func() error, steps []func() error) error {
+	auth := task.Authenticator()
+	for _, sp := range providers {
+		if err := auth.Authenticate(sp); err != nil {
+			return err
+		}
+	}
+
+	reauthentications := 0
+	for _, step := range steps {
+		for {
+			err := Retry(task.RetryAmount, task.RetryDuration, step)
+			if err == nil {
+				break
+			}
+			if err == ScheduleConflict || err == InvalidExportFormat {
+				return err
+			}
+			if err != SessionExpired || reauthentications >= MaxReauthentications {
+				return MaximumAttemptsRetry
+			}
+
+			reauthentications++
+			fmt.Println("Session expired, logging in again")
+			if err := auth.Reauthenticate(providers); err != nil {
+				return err
+			}
+			if restore != nil {
+				if err := Retry(task.RetryAmount, task.RetryDuration, restore); err != nil {
+					return MaximumAttemptsRetry
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (task *Task) checkSession(resp *http.Response, body []byte) error {
+	if resp.StatusCode == 401 {
+		return SessionExpired
+	}
+	if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		location, err := resp.Location()
+		if err == nil && task.isIdentityProviderURL(location) {
+			return SessionExpired
+		}
+	}
+	if resp.Request != nil && task.isIdentityProviderURL(resp.Request.URL) {
+		return SessionExpired
+	}
+	if bytes.Contains(body, []byte("SAMLRequest")) || bytes.Contains(body, []byte("j_username")) {
+		return SessionExpired
+	}
+	return nil
+}
+
+func (task *Task) isIdentityProviderURL(u *url.URL) bool {
+	if u == nil {
+		return false
+	}
+	for _, host := range task.Institution.SSO.Hosts {
+		if u.Host == host {
+			return true
+		}
+	}
+	return strings.Contains(u.Path, "/saml/login")
+}
+
 func formatDuration(time time.Duration) string {
 	totalSeconds := int64(time.Seconds())
 
@@ -171,6 +317,17 @@ func (task *Task) handleLoginMessage(Message string) error {
 }
 
 func (task *Task) sendSuccessfulEnrollmentNotification(CourseTitle string) error {
+	return task.sendNotification("Successful Enrollment", CourseTitle)
+}
+
+func (task *Task) sendWaitlistNotification(CourseTitle string, position int) error {
+	if position > 0 {
+		return task.sendNotification("Waitlisted", fmt.Sprintf("%s (position %d)", CourseTitle, position))
+	}
+	return task.sendNotification("Waitlisted", CourseTitle)
+}
+
+func (task *Task) sendNotification(title string, description string) error {
 
 	if len(task.WebhookURL) == 0 {
 		return NoWebHookURL
@@ -180,9 +337,9 @@ func (task *Task) sendSuccessfulEnrollmentNotification(CourseTitle string) error
 		Username: "Veil",
 		Embeds: []Embed{
 			{
-				Title:       "Successful Enrollment",
+				Title:       title,
 				Color:       5814783,
-				Description: CourseTitle,
+				Description: description,
 				Footer: &Footer{
 					Text: "Veil",
 				},
@@ -210,7 +367,7 @@ func (task *Task) sendSuccessfulEnrollmentNotification(CourseTitle string) error
 		return FailedToMakeRequest
 	}
 	defer resp.Body.Close()
-	if resp.StatusCode <= 201 {
+	if resp.StatusCode >= 300 {
 		return FailedToSendNotification
 	}
 	return nil
@@ -1,25 +1,16 @@
 package tasks
 
 import (
-	"bytes"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
-	"net/url"
-	"os"
-	"strings"
-	"time"
 
-	"github.com/PuerkitoBio/goquery"
 	http "github.com/bogdanfinn/fhttp"
 )
 
 type TranscriptTask struct {
 	task              *Task
-	RelayState        string
-	SAMLResponse      string
-	SAMLRequest       string
 	Name              string
 	UserId            string
 	Degree            string
@@ -29,182 +20,10 @@ type TranscriptTask struct {
 	AuditInfo         []AuditInfo
 }
 
-func (t *TranscriptTask) VisitHomepage() error {
-	fmt.Println("Visiting homepage")
-
-	request, err := http.NewRequest(http.MethodGet, "https://dw-prod.ec.fhda.edu/responsiveDashboard/worksheets/WEB31", nil)
-	if err != nil {
-		return FailedToCreateRequest
-	}
-	request.Header.Add("accept", "*/*")
-	request.Header.Add("accept-language", "en-US,en;q=0.9")
-	request.Header.Add("user-agent", t.task.UserAgent)
-
-	resp, err := t.task.Client.Do(request)
-	if err != nil {
-		return FailedToMakeRequest
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return FailedToReadResponseBody
-	}
-
-	if len(body) > 0 {
-	}
-	return nil
-}
-
-func (t *TranscriptTask) Login() error {
-	fmt.Println("Logging in")
-
-	t.task.LoginAttempts++
-
-	loginData := fmt.Sprintf("j_username=%s&j_password=%s&_eventId_proceed=", t.task.Username, t.task.Password)
-	request, err := http.NewRequest(http.MethodPost, fmt.Sprintf("https://ssoshib.fhda.edu/idp/profile/SAML2/Redirect/SSO?execution=e1s%d", t.task.LoginAttempts), bytes.NewBufferString(loginData))
-	if err != nil {
-		return FailedToCreateRequest
-	}
-	request.Header.Add("accept", "*/*")
-	request.Header.Add("accept-language", "en-US,en;q=0.9")
-	request.Header.Add("content-type", "application/x-www-form-urlencoded")
-	request.Header.Add("user-agent", t.task.UserAgent)
-
-	resp, err := t.task.Client.Do(request)
-	if err != nil {
-		return FailedToMakeRequest
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return FailedToReadResponseBody
-	}
-	reader := strings.NewReader(string(body))
-	document, err := goquery.NewDocumentFromReader(reader)
-	if err != nil {
-		return UnableToGetDocument
-	}
-	var message string
-	document.Find("div[class='alert alert-danger']").Each(func(index int, element *goquery.Selection) {
-		message = strings.TrimSpace(element.Text())
-	})
-
-	if err := t.task.handleLoginMessage(message); err != nil {
-		return err
-	}
-
-	relayStateValue := ""
-	samlResponseValue := ""
-	document.Find("input[name='SAMLResponse']").Each(func(index int, element *goquery.Selection) {
-		value, exists := element.Attr("value")
-		if exists {
-			samlResponseValue = value
-		}
-	})
-	document.Find("input[name='RelayState']").Each(func(index int, element *goquery.Selection) {
-		value, exists := element.Attr("value")
-		if exists {
-			relayStateValue = value
-		}
-	})
-
-	if len(samlResponseValue) == 0 {
-		return NoSamlResponseValue
-	}
-
-	t.RelayState = relayStateValue
-	t.SAMLResponse = samlResponseValue
-
-	return nil
-}
-
-func (t *TranscriptTask) SubmitCommonAuth() error {
-	fmt.Println("Submitting Common Auth SSO")
-
-	values := url.Values{
-		"RelayState":   {t.RelayState},
-		"SAMLResponse": {t.SAMLResponse},
-	}
-
-	request, err := http.NewRequest(http.MethodPost, "https://eis-prod.ec.fhda.edu/commonauth", bytes.NewBufferString(values.Encode()))
-	if err != nil {
-		return FailedToCreateRequest
-	}
-	request.Header.Add("accept", "*/*")
-	request.Header.Add("accept-language", "en-US,en;q=0.9")
-	request.Header.Add("content-type", "application/x-www-form-urlencoded")
-	request.Header.Add("user-agent", t.task.UserAgent)
-
-	resp, err := t.task.Client.Do(request)
-	if err != nil {
-		return FailedToMakeRequest
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return FailedToReadResponseBody
-	}
-	reader := strings.NewReader(string(body))
-	document, err := goquery.NewDocumentFromReader(reader)
-	if err != nil {
-		return UnableToGetDocument
-	}
-
-	samlResponseValue := ""
-	document.Find("input[name='SAMLResponse']").Each(func(index int, element *goquery.Selection) {
-		value, exists := element.Attr("value")
-		if exists {
-			samlResponseValue = value
-		}
-	})
-
-	if len(samlResponseValue) == 0 {
-		return NoSamlResponseValue
-	}
-
-	t.SAMLResponse = samlResponseValue
-	return nil
-}
-
-func (t *TranscriptTask) SubmitSSO() error {
-	fmt.Println("Submitting SSO")
-
-	values := url.Values{
-		"RelayState":   {t.RelayState},
-		"SAMLResponse": {t.SAMLResponse},
-	}
-
-	request, err := http.NewRequest(http.MethodPost, "https://dw-prod.ec.fhda.edu/responsiveDashboard/saml/SSO", bytes.NewBufferString(values.Encode()))
-	if err != nil {
-		return FailedToCreateRequest
-	}
-	request.Header.Add("accept", "*/*")
-	request.Header.Add("accept-language", "en-US,en;q=0.9")
-	request.Header.Add("content-type", "application/x-www-form-urlencoded")
-	request.Header.Add("user-agent", t.task.UserAgent)
-
-	resp, err := t.task.Client.Do(request)
-	if err != nil {
-		return FailedToMakeRequest
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return FailedToReadResponseBody
-	}
-	if len(body) > 0 {
-	}
-	return nil
-}
-
 func (t *TranscriptTask) GetUserInfo() error {
 	fmt.Println("Getting user info")
 
-	request, err := http.NewRequest(http.MethodGet, "https://dw-prod.ec.fhda.edu/responsiveDashboard/api/students/myself", nil)
+	request, err := http.NewRequest(http.MethodGet, t.task.Institution.DegreeWorks("/api/students/myself"), nil)
 	if err != nil {
 		return FailedToCreateRequest
 	}
@@ -222,6 +41,9 @@ func (t *TranscriptTask) GetUserInfo() error {
 	if err != nil {
 		return FailedToReadResponseBody
 	}
+	if err := t.task.checkSession(resp, body); err != nil {
+		return err
+	}
 
 	if len(body) > 0 {
 		userInfo := UserInfo{}
@@ -248,10 +70,10 @@ func (t *TranscriptTask) GetUserInfo() error {
 func (t *TranscriptTask) GetAudit() error {
 	fmt.Printf("Getting audit for %s - %s\n", t.Name, t.SchoolDescription)
 
-	url := fmt.Sprintf("https://dw-prod.ec.fhda.edu/responsiveDashboard/api/audit?studentId=%s&school=%s&degree=%s&is-process-new=false&audit-type=AA&auditId=&include-inprogress=true&include-preregistered=true&aid-term=",
+	url := t.task.Institution.DegreeWorks(fmt.Sprintf("/api/audit?studentId=%s&school=%s&degree=%s&is-process-new=false&audit-type=AA&auditId=&include-inprogress=true&include-preregistered=true&aid-term=",
 		t.UserId,
 		t.SchoolKey,
-		t.Degree)
+		t.Degree))
 
 	request, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
@@ -271,6 +93,9 @@ func (t *TranscriptTask) GetAudit() error {
 	if err != nil {
 		return FailedToReadResponseBody
 	}
+	if err := t.task.checkSession(resp, body); err != nil {
+		return err
+	}
 
 	if len(body) > 0 {
 		var auditInfo []AuditInfo
@@ -295,23 +120,62 @@ func (t *TranscriptTask) GetAudit() error {
 	return nil
 }
 
+type Transcript struct {
+	Name              string      `json:"name"`
+	UserId            string      `json:"userId"`
+	Degree            string      `json:"degree"`
+	DegreeDescription string      `json:"degreeDescription"`
+	SchoolKey         string      `json:"schoolKey"`
+	SchoolDescription string      `json:"schoolDescription"`
+	Classes           []AuditInfo `json:"classes"`
+}
+
+func (t *TranscriptTask) Transcript() Transcript {
+	return Transcript{
+		Name:              t.Name,
+		UserId:            t.UserId,
+		Degree:            t.Degree,
+		DegreeDescription: t.DegreeDescription,
+		SchoolKey:         t.SchoolKey,
+		SchoolDescription: t.SchoolDescription,
+		Classes:           t.AuditInfo,
+	}
+}
+
 func (t *TranscriptTask) ExportTranscript() error {
 	fmt.Println("Exporting transcript")
 
-	currentTime := time.Now()
-	fileName := fmt.Sprintf("%s-%s-%s.csv", t.Name, t.Degree, currentTime.Format("2006-01-02_15-04-05"))
-	file, err := os.Create(fileName)
+	format := t.task.exportFormat()
+	if format == FormatICS || format.Grid() {
+		return InvalidExportFormat
+	}
+	output, closeOutput, err := t.task.createOutput(fmt.Sprintf("%s-%s", t.Name, t.Degree), string(format))
 	if err != nil {
-		fmt.Println(err)
+		return err
 	}
-	defer file.Close()
+	defer closeOutput()
 
-	writer := csv.NewWriter(file)
+	switch format {
+	case FormatJSON:
+		err = writeJSONExport(output, t.Transcript())
+	case FormatNDJSON:
+		err = writeNDJSONExport(output, t.AuditInfo)
+	default:
+		err = t.writeCSV(output)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println("Exported transcript data")
+	return nil
+}
+
+func (t *TranscriptTask) writeCSV(output io.Writer) error {
+	writer := csv.NewWriter(output)
 	defer writer.Flush()
 
 	header := []string{"Term", "Section", "Number", "Course Title", "Letter Grade", "Credits"}
-	fmt.Printf("Writing to %s\n", fileName)
-	err = writer.Write(header)
+	err := writer.Write(header)
 	if err != nil {
 		return FailedToWrite
 	}
@@ -329,25 +193,22 @@ func (t *TranscriptTask) ExportTranscript() error {
 			return FailedToWrite
 		}
 	}
-	fmt.Println("Exported transcript data")
 	return nil
 }
 
 func (t *TranscriptTask) Run() error {
+	// Check the format before logging in, since it cannot be retried.
+	if format := t.task.exportFormat(); format == FormatICS || format.Grid() {
+		return InvalidExportFormat
+	}
 	steps := []func() error{
-		t.VisitHomepage,
-		t.Login,
-		t.SubmitCommonAuth,
-		t.SubmitSSO,
 		t.GetUserInfo,
 		t.GetAudit,
 		t.ExportTranscript,
 	}
 
-	for _, step := range steps {
-		if err := Retry(t.task.RetryAmount, t.task.RetryDuration, step); err != nil {
-			return MaximumAttemptsRetry
-		}
+	if err := t.task.RunAuthenticated(t.task.Institution.SSO.DegreeWorks, nil, steps); err != nil {
+		return err
 	}
 
 	t.task.Client.CloseIdleConnections()
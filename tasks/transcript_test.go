@@ -0,0 +1,93 @@
+package tasks
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTranscriptTaskExportsAudit(t *testing.T) {
+	server := newMockServer(t)
+	task := newMockTask(t, server)
+	dir := chdirTemp(t)
+
+	transcript := NewTranscriptTask(task)
+	if err := transcript.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if transcript.Name != "Ada Lovelace" || transcript.Degree != "AA" {
+		t.Errorf("user info = %q %q", transcript.Name, transcript.Degree)
+	}
+	if len(transcript.AuditInfo) != 2 {
+		t.Fatalf("got %d classes, want 2", len(transcript.AuditInfo))
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.csv"))
+	if len(files) != 1 {
+		t.Fatalf("got %d csv files, want 1", len(files))
+	}
+	data, _ := os.ReadFile(files[0])
+	if !strings.Contains(string(data), "COMPOSITION") {
+		t.Errorf("export missing class:\n%s", data)
+	}
+}
+
+func TestTranscriptTaskWritesJSONToStdout(t *testing.T) {
+	server := newMockServer(t)
+	task := newMockTask(t, server)
+	dir := chdirTemp(t)
+	stdout := &bytes.Buffer{}
+	task.OutputFormat = FormatJSON
+	task.Output = StdoutOutput
+	task.Stdout = stdout
+
+	transcript := NewTranscriptTask(task)
+	if err := transcript.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	exported := Transcript{}
+	if err := json.Unmarshal(stdout.Bytes(), &exported); err != nil {
+		t.Fatalf("%v:\n%s", err, stdout)
+	}
+	if exported.Name != "Ada Lovelace" || len(exported.Classes) != 2 || exported.Classes[1].LetterGrade != "B+" {
+		t.Errorf("unexpected transcript %+v", exported)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*")); len(files) != 0 {
+		t.Errorf("unexpected files %v", files)
+	}
+}
+
+func TestTranscriptTaskRejectsGridFormats(t *testing.T) {
+	server := newMockServer(t)
+	task := newMockTask(t, server)
+	task.RetryDuration = time.Hour
+	dir := chdirTemp(t)
+
+	transcript := NewTranscriptTask(task)
+	for _, format := range []ExportFormat{FormatICS, FormatANSI, FormatHTML, FormatSVG} {
+		task.OutputFormat = format
+		if err := transcript.Run(); err != InvalidExportFormat {
+			t.Errorf("%s: err = %v, want InvalidExportFormat", format, err)
+		}
+	}
+	if server.Logins() != 0 {
+		t.Errorf("logged in %d times before rejecting the format", server.Logins())
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*")); len(files) != 0 {
+		t.Errorf("unexpected files %v", files)
+	}
+
+	calls := 0
+	err := Retry(3, time.Hour, func() error {
+		calls++
+		return InvalidExportFormat
+	})
+	if err != InvalidExportFormat || calls != 1 {
+		t.Errorf("Retry = %v after %d calls, want InvalidExportFormat after 1", err, calls)
+	}
+}
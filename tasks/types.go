@@ -42,118 +42,49 @@ var (
 	FailedToSendNotification         = errors.New("Failed to send discord notification")
 	NoSamlResponseValue              = errors.New("No SAML Response value")
 	NoStudentsFound                  = errors.New("No students found")
+	NoSavedSession                   = errors.New("No saved session")
+	FailedLoadingSession             = errors.New("Failed loading session")
+	FailedSavingSession              = errors.New("Failed saving session")
+	SessionInvalid                   = errors.New("Saved session is no longer valid")
+	SessionExpired                   = errors.New("Session expired")
+	FailedLoadingInstitution         = errors.New("Failed loading institution profile")
+	InvalidInstitution               = errors.New("Invalid institution profile")
+	InvalidTimeOfDay                 = errors.New("Invalid time of day")
+	InvalidDays                      = errors.New("Invalid days, expected MTWRFSU letters")
+	InvalidCreditHours               = errors.New("Invalid credit hours")
+	FailedResettingSearch            = errors.New("Failed resetting search")
+	InvalidExportFormat              = errors.New("Invalid export format")
+	FailedGettingMeetingTimes        = errors.New("Failed getting meeting times")
+	FailedGettingLinkedSections      = errors.New("Failed getting linked sections")
+	FailedOpeningSnapshotStore       = errors.New("Failed opening snapshot store")
+	FailedSavingSnapshot             = errors.New("Failed saving snapshot")
+	FailedQueryingSnapshots          = errors.New("Failed querying snapshots")
+	NoSnapshotStore                  = errors.New("No snapshot store set")
+	NoCRNsToWatch                    = errors.New("No CRNs to watch")
+	EmptyCart                        = errors.New("No courses in the registration cart")
+	InvalidSwap                      = errors.New("Invalid swap, expected DROP:ADD CRN pairs")
+	FailedReadingServerClock         = errors.New("Failed reading the server clock")
+	ScheduleConflict                 = errors.New("Courses to add overlap in time")
+	InvalidCourse                    = errors.New("Invalid course, expected SUBJECT NUMBER")
+	NoSchedulesFound                 = errors.New("No conflict-free schedules found")
+	FailedGettingHistory             = errors.New("Failed getting registration history")
+	IncompleteSearch                 = errors.New("Search returned fewer sections than it found")
+	DryRunSubmit                     = errors.New("Dry run tried to submit registration changes")
 )
 
-var QuarterCodes = map[string]int{
-	"summer": 1,
-	"fall":   2,
-	"winter": 3,
-	"spring": 4,
-}
-
-var CampusCodes = map[string]int{
-	"fh": 1,
-	"da": 2,
-}
-
 type Terms []struct {
 	Code        string `json:"code"`
 	Description string `json:"description"`
 }
 
 type Courses struct {
-	Success    bool `json:"success"`
-	TotalCount int  `json:"totalCount"`
-	Data       []struct {
-		ID                      int    `json:"id"`
-		Term                    string `json:"term"`
-		TermDesc                string `json:"termDesc"`
-		CourseReferenceNumber   string `json:"courseReferenceNumber"`
-		PartOfTerm              string `json:"partOfTerm"`
-		CourseNumber            string `json:"courseNumber"`
-		Subject                 string `json:"subject"`
-		SubjectDescription      string `json:"subjectDescription"`
-		SequenceNumber          string `json:"sequenceNumber"`
-		CampusDescription       string `json:"campusDescription"`
-		ScheduleTypeDescription string `json:"scheduleTypeDescription"`
-		CourseTitle             string `json:"courseTitle"`
-		CreditHours             any    `json:"creditHours"`
-		MaximumEnrollment       int    `json:"maximumEnrollment"`
-		Enrollment              int    `json:"enrollment"`
-		SeatsAvailable          int    `json:"seatsAvailable"`
-		WaitCapacity            int    `json:"waitCapacity"`
-		WaitCount               int    `json:"waitCount"`
-		WaitAvailable           int    `json:"waitAvailable"`
-		CrossList               any    `json:"crossList"`
-		CrossListCapacity       any    `json:"crossListCapacity"`
-		CrossListCount          any    `json:"crossListCount"`
-		CrossListAvailable      any    `json:"crossListAvailable"`
-		CreditHourHigh          any    `json:"creditHourHigh"`
-		CreditHourIndicator     any    `json:"creditHourIndicator"`
-		OpenSection             bool   `json:"openSection"`
-		LinkIdentifier          any    `json:"linkIdentifier"`
-		IsSectionLinked         bool   `json:"isSectionLinked"`
-		SubjectCourse           string `json:"subjectCourse"`
-		Faculty                 []struct {
-			BannerID              string `json:"bannerId"`
-			Category              any    `json:"category"`
-			Class                 string `json:"class"`
-			CourseReferenceNumber string `json:"courseReferenceNumber"`
-			DisplayName           string `json:"displayName"`
-			EmailAddress          any    `json:"emailAddress"`
-			PrimaryIndicator      bool   `json:"primaryIndicator"`
-			Term                  string `json:"term"`
-		} `json:"faculty"`
-		MeetingsFaculty []struct {
-			Category              string `json:"category"`
-			Class                 string `json:"class"`
-			CourseReferenceNumber string `json:"courseReferenceNumber"`
-			Faculty               []any  `json:"faculty"`
-			MeetingTime           struct {
-				BeginTime              string  `json:"beginTime"`
-				Building               string  `json:"building"`
-				BuildingDescription    string  `json:"buildingDescription"`
-				Campus                 string  `json:"campus"`
-				CampusDescription      string  `json:"campusDescription"`
-				Category               string  `json:"category"`
-				Class                  string  `json:"class"`
-				CourseReferenceNumber  string  `json:"courseReferenceNumber"`
-				CreditHourSession      float64 `json:"creditHourSession"`
-				EndDate                string  `json:"endDate"`
-				EndTime                string  `json:"endTime"`
-				Friday                 bool    `json:"friday"`
-				HoursWeek              float64 `json:"hoursWeek"`
-				MeetingScheduleType    string  `json:"meetingScheduleType"`
-				MeetingType            string  `json:"meetingType"`
-				MeetingTypeDescription string  `json:"meetingTypeDescription"`
-				Monday                 bool    `json:"monday"`
-				Room                   string  `json:"room"`
-				Saturday               bool    `json:"saturday"`
-				StartDate              string  `json:"startDate"`
-				Sunday                 bool    `json:"sunday"`
-				Term                   string  `json:"term"`
-				Thursday               bool    `json:"thursday"`
-				Tuesday                bool    `json:"tuesday"`
-				Wednesday              bool    `json:"wednesday"`
-			} `json:"meetingTime"`
-			Term string `json:"term"`
-		} `json:"meetingsFaculty"`
-		ReservedSeatSummary any `json:"reservedSeatSummary"`
-		SectionAttributes   []struct {
-			Class                 string `json:"class"`
-			Code                  string `json:"code"`
-			CourseReferenceNumber string `json:"courseReferenceNumber"`
-			Description           string `json:"description"`
-			IsZTCAttribute        bool   `json:"isZTCAttribute"`
-			TermCode              string `json:"termCode"`
-		} `json:"sectionAttributes"`
-		InstructionalMethod            string `json:"instructionalMethod"`
-		InstructionalMethodDescription string `json:"instructionalMethodDescription"`
-	} `json:"data"`
-	PageOffset           int    `json:"pageOffset"`
-	PageMaxSize          int    `json:"pageMaxSize"`
-	SectionsFetchedCount int    `json:"sectionsFetchedCount"`
-	PathMode             string `json:"pathMode"`
+	Success              bool            `json:"success"`
+	TotalCount           int             `json:"totalCount"`
+	Data                 []CourseSection `json:"data"`
+	PageOffset           int             `json:"pageOffset"`
+	PageMaxSize          int             `json:"pageMaxSize"`
+	SectionsFetchedCount int             `json:"sectionsFetchedCount"`
+	PathMode             string          `json:"pathMode"`
 	SearchResultsConfigs []struct {
 		Config   string `json:"config"`
 		Display  string `json:"display"`
@@ -164,24 +95,142 @@ type Courses struct {
 	ZtcEncodedImage string `json:"ztcEncodedImage"`
 }
 
-type CourseInfo struct {
-	TermDesc              string
-	CourseReferenceNumber string
-	Subject               string
-	CourseNumber          string
-	SequenceNumber        string
-	CourseTitle           string
-	DisplayName           string
-	BeginTime             string
-	EndTime               string
-	StartDate             string
-	EndDate               string
-	MeetingType           string
-	Room                  string
-	MaximumEnrollment     int
-	Enrollment            int
-	SeatsAvailable        int
-	WaitAvailable         int
+type CourseSection struct {
+	ID                             int                `json:"id"`
+	Term                           string             `json:"term"`
+	TermDesc                       string             `json:"termDesc"`
+	CourseReferenceNumber          string             `json:"courseReferenceNumber"`
+	PartOfTerm                     string             `json:"partOfTerm"`
+	CourseNumber                   string             `json:"courseNumber"`
+	Subject                        string             `json:"subject"`
+	SubjectDescription             string             `json:"subjectDescription"`
+	SequenceNumber                 string             `json:"sequenceNumber"`
+	CampusDescription              string             `json:"campusDescription"`
+	ScheduleTypeDescription        string             `json:"scheduleTypeDescription"`
+	CourseTitle                    string             `json:"courseTitle"`
+	CreditHours                    any                `json:"creditHours"`
+	MaximumEnrollment              int                `json:"maximumEnrollment"`
+	Enrollment                     int                `json:"enrollment"`
+	SeatsAvailable                 int                `json:"seatsAvailable"`
+	WaitCapacity                   int                `json:"waitCapacity"`
+	WaitCount                      int                `json:"waitCount"`
+	WaitAvailable                  int                `json:"waitAvailable"`
+	CrossList                      any                `json:"crossList"`
+	CrossListCapacity              any                `json:"crossListCapacity"`
+	CrossListCount                 any                `json:"crossListCount"`
+	CrossListAvailable             any                `json:"crossListAvailable"`
+	CreditHourLow                  any                `json:"creditHourLow"`
+	CreditHourHigh                 any                `json:"creditHourHigh"`
+	CreditHourIndicator            any                `json:"creditHourIndicator"`
+	OpenSection                    bool               `json:"openSection"`
+	LinkIdentifier                 any                `json:"linkIdentifier"`
+	IsSectionLinked                bool               `json:"isSectionLinked"`
+	SubjectCourse                  string             `json:"subjectCourse"`
+	Faculty                        []Faculty          `json:"faculty"`
+	MeetingsFaculty                []MeetingFaculty   `json:"meetingsFaculty"`
+	ReservedSeatSummary            any                `json:"reservedSeatSummary"`
+	SectionAttributes              []SectionAttribute `json:"sectionAttributes"`
+	InstructionalMethod            string             `json:"instructionalMethod"`
+	InstructionalMethodDescription string             `json:"instructionalMethodDescription"`
+}
+
+type Faculty struct {
+	BannerID              string `json:"bannerId"`
+	Category              any    `json:"category"`
+	Class                 string `json:"class"`
+	CourseReferenceNumber string `json:"courseReferenceNumber"`
+	DisplayName           string `json:"displayName"`
+	EmailAddress          string `json:"emailAddress"`
+	PrimaryIndicator      bool   `json:"primaryIndicator"`
+	Term                  string `json:"term"`
+}
+
+type MeetingFaculty struct {
+	Category              string      `json:"category"`
+	Class                 string      `json:"class"`
+	CourseReferenceNumber string      `json:"courseReferenceNumber"`
+	Faculty               []Faculty   `json:"faculty"`
+	MeetingTime           MeetingTime `json:"meetingTime"`
+	Term                  string      `json:"term"`
+}
+
+type MeetingTime struct {
+	BeginTime              string  `json:"beginTime"`
+	Building               string  `json:"building"`
+	BuildingDescription    string  `json:"buildingDescription"`
+	Campus                 string  `json:"campus"`
+	CampusDescription      string  `json:"campusDescription"`
+	Category               string  `json:"category"`
+	Class                  string  `json:"class"`
+	CourseReferenceNumber  string  `json:"courseReferenceNumber"`
+	CreditHourSession      float64 `json:"creditHourSession"`
+	EndDate                string  `json:"endDate"`
+	EndTime                string  `json:"endTime"`
+	Friday                 bool    `json:"friday"`
+	HoursWeek              float64 `json:"hoursWeek"`
+	MeetingScheduleType    string  `json:"meetingScheduleType"`
+	MeetingType            string  `json:"meetingType"`
+	MeetingTypeDescription string  `json:"meetingTypeDescription"`
+	Monday                 bool    `json:"monday"`
+	Room                   string  `json:"room"`
+	Saturday               bool    `json:"saturday"`
+	StartDate              string  `json:"startDate"`
+	Sunday                 bool    `json:"sunday"`
+	Term                   string  `json:"term"`
+	Thursday               bool    `json:"thursday"`
+	Tuesday                bool    `json:"tuesday"`
+	Wednesday              bool    `json:"wednesday"`
+}
+
+type MeetingTimes struct {
+	Fmt []MeetingFaculty `json:"fmt"`
+}
+
+type LinkedSections struct {
+	LinkedData [][]CourseSection `json:"linkedData"`
+}
+
+type RegistrationHistory struct {
+	Success bool `json:"success"`
+	Data    struct {
+		Registrations []RegistrationRecord `json:"registrations"`
+	} `json:"data"`
+}
+
+type RegistrationRecord struct {
+	CourseReferenceNumber    string  `json:"courseReferenceNumber"`
+	Term                     string  `json:"term"`
+	Subject                  string  `json:"subject"`
+	CourseNumber             string  `json:"courseNumber"`
+	SequenceNumber           string  `json:"sequenceNumber"`
+	CourseTitle              string  `json:"courseTitle"`
+	Campus                   string  `json:"campus"`
+	ScheduleDescription      string  `json:"scheduleDescription"`
+	CourseRegistrationStatus string  `json:"courseRegistrationStatus"`
+	StatusDescription        string  `json:"statusDescription"`
+	CreditHour               float64 `json:"creditHour"`
+	WaitPosition             any     `json:"waitPosition"`
+}
+
+type RegistrationEvent struct {
+	ID           any    `json:"id"`
+	CRN          string `json:"crn"`
+	Term         string `json:"term"`
+	Subject      string `json:"subject"`
+	CourseNumber string `json:"courseNumber"`
+	Title        string `json:"title"`
+	Start        string `json:"start"`
+	End          string `json:"end"`
+	AllDay       bool   `json:"allDay"`
+}
+
+type SectionAttribute struct {
+	Class                 string `json:"class"`
+	Code                  string `json:"code"`
+	CourseReferenceNumber string `json:"courseReferenceNumber"`
+	Description           string `json:"description"`
+	IsZTCAttribute        bool   `json:"isZTCAttribute"`
+	TermCode              string `json:"termCode"`
 }
 
 type RegistrationStatus struct {
@@ -207,6 +256,9 @@ type BatchUpdate struct {
 	Create  []map[string]interface{} `json:"create"`
 	Update  []map[string]interface{} `json:"update"`
 	Destroy []map[string]interface{} `json:"destroy"`
+	// ConditionalAddDrop asks Banner to only process the drops in the batch
+	// when every add succeeds.
+	ConditionalAddDrop bool `json:"conditionalAddDrop,omitempty"`
 }
 
 type Changes struct {
@@ -281,6 +333,7 @@ type Changes struct {
 			StructuredRegistrationDetailSequence any    `json:"structuredRegistrationDetailSequence"`
 			RegistrationFromDate                 any    `json:"registrationFromDate"`
 			WaitCapacity                         any    `json:"waitCapacity"`
+			WaitPosition                         any    `json:"waitPosition"`
 			BlockRuleSequenceNumber              any    `json:"blockRuleSequenceNumber"`
 			BillHours                            struct {
 				Class               string `json:"class"`
@@ -474,12 +527,12 @@ type UserInfo struct {
 }
 
 type AuditInfo struct {
-	Term        string
-	Section     string
-	Number      string
-	CourseTitle string
-	LetterGrade string
-	Credits     string
+	Term        string `json:"term"`
+	Section     string `json:"section"`
+	Number      string `json:"number"`
+	CourseTitle string `json:"courseTitle"`
+	LetterGrade string `json:"letterGrade"`
+	Credits     string `json:"credits"`
 }
 
 type Audit struct {
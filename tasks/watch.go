@@ -0,0 +1,200 @@
+package tasks
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	tls_client "github.com/bogdanfinn/tls-client"
+)
+
+const (
+	DefaultPollInterval = time.Minute
+	MaxPollBackoff      = 30 * time.Minute
+	// After this many failed polls in a row the search session is dropped and
+	// a new JSESSIONID is started on the next poll.
+	MaxPollFailures = 3
+)
+
+type SeatChange struct {
+	Section Section
+	Kind    string
+}
+
+const (
+	SeatsOpened    = "seats"
+	WaitlistOpened = "waitlist"
+)
+
+func (c SeatChange) String() string {
+	section := c.Section
+	if c.Kind == WaitlistOpened {
+		return fmt.Sprintf("Waitlist open for %s %s %s-%s %s: %d of %d waitlist seats available",
+			section.CRN, section.Subject, section.CourseNumber, section.SequenceNumber, section.Title,
+			section.WaitAvailable, section.WaitCapacity)
+	}
+	return fmt.Sprintf("Seats open for %s %s %s-%s %s: %d of %d seats available",
+		section.CRN, section.Subject, section.CourseNumber, section.SequenceNumber, section.Title,
+		section.SeatsAvailable, section.MaximumEnrollment)
+}
+
+type WatchTask struct {
+	task     *Task
+	search   *SearchTask
+	last     map[string]Section
+	failures int
+	// sharedSession is set when the watcher polls on the registration client,
+	// whose session must survive failed polls.
+	sharedSession bool
+	// MaxPolls stops Run after that many polls, 0 polls forever.
+	MaxPolls int
+	// OnChange is called for every change, after the notification is sent.
+	OnChange func(SeatChange)
+}
+
+func (w *WatchTask) crns() []string {
+	crns := w.task.WatchCRNs
+	if len(crns) == 0 {
+		crns = w.task.CoursesToAdd
+	}
+	var watched []string
+	for _, crn := range crns {
+		if crn = strings.TrimSpace(crn); len(crn) > 0 {
+			watched = append(watched, crn)
+		}
+	}
+	return watched
+}
+
+// Poll searches once and returns the watched sections whose seats or
+// waitlist went from none to some since the previous poll.
+func (w *WatchTask) Poll() ([]SeatChange, error) {
+	if err := w.search.GetCourses(); err != nil {
+		return nil, err
+	}
+	if w.task.Snapshots != nil {
+		if err := w.search.PersistSnapshots(); err != nil {
+			fmt.Println(err)
+		}
+	}
+
+	sections := map[string]Section{}
+	for _, section := range w.search.Sections() {
+		sections[section.CRN] = section
+	}
+
+	var changes []SeatChange
+	for _, crn := range w.crns() {
+		section, ok := sections[crn]
+		if !ok {
+			fmt.Printf("Warning: %s not found in search results\n", crn)
+			continue
+		}
+		previous, seen := w.last[crn]
+		w.last[crn] = section
+		if !seen {
+			fmt.Printf("Watching %s %s %s-%s: %d seats, %d waitlist seats\n",
+				crn, section.Subject, section.CourseNumber, section.SequenceNumber, section.SeatsAvailable, section.WaitAvailable)
+			continue
+		}
+
+		if previous.SeatsAvailable <= 0 && section.SeatsAvailable > 0 {
+			changes = append(changes, SeatChange{Section: section, Kind: SeatsOpened})
+		}
+		if previous.WaitAvailable <= 0 && section.WaitAvailable > 0 {
+			changes = append(changes, SeatChange{Section: section, Kind: WaitlistOpened})
+		}
+		if previous.SeatsAvailable != section.SeatsAvailable || previous.WaitAvailable != section.WaitAvailable {
+			fmt.Printf("%s: seats %d -> %d, waitlist seats %d -> %d\n",
+				crn, previous.SeatsAvailable, section.SeatsAvailable, previous.WaitAvailable, section.WaitAvailable)
+		}
+	}
+	return changes, nil
+}
+
+func (w *WatchTask) notify(change SeatChange) {
+	fmt.Println(change)
+	title := "Seats Open"
+	if change.Kind == WaitlistOpened {
+		title = "Waitlist Open"
+	}
+	if err := w.task.sendNotification(title, change.String()); err != nil {
+		fmt.Println(err)
+	}
+	if w.OnChange != nil {
+		w.OnChange(change)
+	}
+}
+
+// pollFailed counts a failed poll and starts a new search session after
+// every MaxPollFailures failures in a row, unless the session is shared with
+// registration.
+func (w *WatchTask) pollFailed(err error) {
+	w.failures++
+	fmt.Printf("Poll failed (%d in a row): %s\n", w.failures, err)
+	if w.failures%MaxPollFailures == 0 && !w.sharedSession {
+		fmt.Println("Starting a new search session")
+		w.task.Client.SetCookieJar(tls_client.NewCookieJar())
+	}
+}
+
+// nextPoll returns how long to wait before polling again: the interval plus
+// up to PollJitter of random jitter, or an exponential backoff after failures.
+func (w *WatchTask) nextPoll() time.Duration {
+	interval := w.task.PollInterval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	if w.failures > 0 {
+		backoff := interval
+		for i := 1; i < w.failures && backoff < MaxPollBackoff; i++ {
+			backoff *= 2
+		}
+		if backoff > MaxPollBackoff {
+			backoff = MaxPollBackoff
+		}
+		return backoff
+	}
+	if w.task.PollJitter > 0 {
+		interval += time.Duration(rand.Int63n(int64(w.task.PollJitter)))
+	}
+	return interval
+}
+
+func (w *WatchTask) Run() error {
+	if len(w.crns()) == 0 {
+		return NoCRNsToWatch
+	}
+	fmt.Printf("Watching %s\n", strings.Join(w.crns(), ", "))
+
+	for polls := 1; ; polls++ {
+		changes, err := w.Poll()
+		if err != nil {
+			w.pollFailed(err)
+		} else {
+			w.failures = 0
+			for _, change := range changes {
+				w.notify(change)
+			}
+		}
+
+		if w.MaxPolls > 0 && polls >= w.MaxPolls {
+			break
+		}
+		wait := w.nextPoll()
+		fmt.Printf("Next poll in %s\n", wait.Round(time.Second))
+		time.Sleep(wait)
+	}
+
+	w.task.Client.CloseIdleConnections()
+	return nil
+}
+
+func NewWatchTask(task *Task) *WatchTask {
+	return &WatchTask{
+		task:   task,
+		search: NewSearchTask(task),
+		last:   map[string]Section{},
+	}
+}
@@ -0,0 +1,106 @@
+package tasks
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchTaskDetectsOpenings(t *testing.T) {
+	server := newMockServer(t)
+	task := newMockTask(t, server)
+	task.WatchCRNs = []string{"30002"}
+	server.Sections[1].WaitCount = 10
+
+	watch := NewWatchTask(task)
+	if changes, err := watch.Poll(); err != nil || len(changes) != 0 {
+		t.Fatalf("first poll = %v, %v", changes, err)
+	}
+
+	server.Sections[1].WaitCount = 9
+	changes, err := watch.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Kind != WaitlistOpened {
+		t.Fatalf("got %+v, want waitlist opening", changes)
+	}
+
+	server.Sections[1].Enrolled = 38
+	changes, err = watch.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Kind != SeatsOpened || changes[0].Section.SeatsAvailable != 2 {
+		t.Fatalf("got %+v, want seats opening", changes)
+	}
+}
+
+func TestWatchTaskNotifiesThroughWebhook(t *testing.T) {
+	server := newMockServer(t)
+	task := newMockTask(t, server)
+	task.WatchCRNs = []string{"30002"}
+	task.WebhookURL = server.WebhookURL()
+	task.PollInterval = time.Millisecond
+
+	searches := 0
+	server.BeforeRequest = func(r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/searchResults/searchResults") {
+			searches++
+			if searches == 2 {
+				server.Sections[1].Enrolled = 39
+			}
+		}
+	}
+
+	var changes []SeatChange
+	watch := NewWatchTask(task)
+	watch.MaxPolls = 2
+	watch.OnChange = func(change SeatChange) {
+		changes = append(changes, change)
+	}
+	if err := watch.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Section.CRN != "30002" {
+		t.Fatalf("got %+v", changes)
+	}
+	webhooks := server.Webhooks()
+	if len(webhooks) != 1 || !strings.Contains(webhooks[0], "Seats open for 30002") {
+		t.Errorf("webhooks = %v", webhooks)
+	}
+}
+
+func TestWatchTaskBacksOff(t *testing.T) {
+	task := &Task{PollInterval: time.Minute}
+	watch := NewWatchTask(task)
+	for failures, want := range []time.Duration{time.Minute, time.Minute, 2 * time.Minute, 4 * time.Minute} {
+		watch.failures = failures
+		if got := watch.nextPoll(); got != want {
+			t.Errorf("failures %d: got %s, want %s", failures, got, want)
+		}
+	}
+	watch.failures = 20
+	if got := watch.nextPoll(); got != MaxPollBackoff {
+		t.Errorf("got %s, want %s", got, MaxPollBackoff)
+	}
+}
+
+func TestWatchTaskResetsSessionAfterFailures(t *testing.T) {
+	server := newMockServer(t)
+	task := newMockTask(t, server)
+	watch := NewWatchTask(task)
+
+	jar := task.Client.GetCookieJar()
+	for i := 1; i < MaxPollFailures; i++ {
+		watch.pollFailed(FailedToMakeRequest)
+	}
+	if task.Client.GetCookieJar() != jar {
+		t.Fatalf("new session after %d failures", MaxPollFailures-1)
+	}
+	watch.pollFailed(FailedToMakeRequest)
+	if task.Client.GetCookieJar() == jar {
+		t.Errorf("no new session after %d failures", MaxPollFailures)
+	}
+}